@@ -1,10 +1,16 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
-	"github.com/ondrovic/folder-organizer/internal/types"
 	"github.com/ondrovic/folder-organizer/internal/utils"
+	"github.com/ondrovic/folder-organizer/pkg/organizer"
 
 	"github.com/spf13/cobra"
 )
@@ -12,9 +18,9 @@ import (
 var (
 	organizeCmd = &cobra.Command{
 		Use:   "organize <config-file-path> <folder-to-organize>",
-		Short: "Organize the specified folder based on the JSON configuration",
+		Short: "Organize the specified folder based on the configuration file",
 		Long: `Organize files in a directory by sorting them into subdirectories based on file extensions.
-The organization structure is defined in a JSON configuration file.
+The organization structure is defined in a JSON, YAML or TOML configuration file.
 Example config file:
 {
   "categories": {
@@ -31,43 +37,299 @@ Example config file:
 func init() {
 	// Add flags to the organize command
 	organizeCmd.Flags().IntVarP(&options.NumOfWorkers, "workers", "w", 4, "Number of worker goroutines")
+	organizeCmd.Flags().IntVar(&options.ScanWorkers, "scan-workers", 1, "Number of directories read at the same time, raise for network filesystems")
 	organizeCmd.Flags().BoolVarP(&options.Recursive, "recursive", "r", true, "Process subdirectories recursively")
 	organizeCmd.Flags().BoolVarP(&options.ShowProgress, "progress", "p", true, "Show progress during organization")
 	organizeCmd.Flags().BoolVarP(&options.CleanupEmptyDirs, "cleanup", "c", true, "Remove empty directories after organization")
+	organizeCmd.Flags().BoolVarP(&options.DryRun, "dry-run", "n", false, "Print the planned moves without changing anything")
+	organizeCmd.Flags().StringVarP(&options.DestPath, "dest", "d", "", "Move organized files into this directory instead of organizing in place")
+	organizeCmd.Flags().StringVarP(&options.Mode, "mode", "m", organizer.ModeMove, "How files are placed in their target folder: "+strings.Join(organizer.Modes, ", "))
+	organizeCmd.Flags().StringVar(&options.OnConflict, "on-conflict", organizer.ConflictRename, "What to do when the target file exists: "+strings.Join(organizer.ConflictStrategies, ", "))
+	organizeCmd.Flags().BoolVar(&options.DetectContent, "detect-content", false, "Classify files by their content when the name does not match or the content contradicts the extension")
+	organizeCmd.Flags().StringArrayVar(&options.Exclude, "exclude", nil, "Gitignore-style pattern of files and directories to leave alone (repeatable)")
+	organizeCmd.Flags().DurationVar(&options.MinAge, "min-age", 0, "Leave files modified more recently than this alone, e.g. 10m")
+	organizeCmd.Flags().IntVar(&options.MaxFailures, "max-failures", 0, "Number of files that may fail to be organized before exiting with status 2, negative to never fail")
+	organizeCmd.Flags().StringVar(&options.ListSkipped, "list-skipped", "", "List the skipped files for a reason, or all of them: "+strings.Join(organizer.SkipReasons, ", ")+", "+listSkippedAll)
+	organizeCmd.Flags().StringVarP(&options.Output, "output", "o", outputText, "Output format: "+strings.Join(outputFormats, ", "))
+	organizeCmd.Flags().StringVar(&options.ConfigFormat, "config-format", "", "Config file format: json, yaml or toml (default: detected from the file extension)")
+	organizeCmd.Flags().StringVar(&options.JournalDir, "journal-dir", "", "Directory where run journals are stored (default: user config directory)")
 }
 
 func runOrganize(cmd *cobra.Command, args []string) error {
+	// Arguments are valid, runtime errors should not print the usage
+	cmd.SilenceUsage = true
+
 	options.ConfigurationPath = args[0]
 	options.Directory = args[1]
 
-	// Configure organization options
-	opts := types.OrganizeOptions{
-		ConfigPath:   options.ConfigurationPath,
-		SourcePath:   options.Directory,
-		NumWorkers:   options.NumOfWorkers,
-		Recursive:    options.Recursive,
-		ShowProgress: options.ShowProgress,
+	if !slices.Contains(outputFormats, options.Output) {
+		return fmt.Errorf("unknown output format %q (expected one of: %s)", options.Output, strings.Join(outputFormats, ", "))
+	}
+	jsonOutput := options.Output == outputJSON
+	if options.ListSkipped != "" && options.ListSkipped != listSkippedAll && !slices.Contains(organizer.SkipReasons, options.ListSkipped) {
+		return fmt.Errorf("unknown skip reason %q (expected one of: %s, %s)", options.ListSkipped, strings.Join(organizer.SkipReasons, ", "), listSkippedAll)
 	}
 
-	// Run the organization
-	stats, err := utils.OrganizeFiles(opts)
+	org, err := newOrganizer()
 	if err != nil {
 		return err
 	}
 
+	// Configure organization options
+	opts := organizer.Options{
+		SourcePath:    options.Directory,
+		DestPath:      options.DestPath,
+		NumWorkers:    options.NumOfWorkers,
+		ScanWorkers:   options.ScanWorkers,
+		Recursive:     options.Recursive,
+		DryRun:        options.DryRun,
+		OnConflict:    options.OnConflict,
+		Mode:          options.Mode,
+		DetectContent: options.DetectContent,
+		Exclude:       options.Exclude,
+		MinAge:        options.MinAge,
+		RecordFiles:   options.DryRun || jsonOutput || options.ListSkipped != "",
+	}
+
+	// Journal every change so the run can be undone
+	var journal *organizer.Journal
+	if !options.DryRun {
+		journalDir, err := resolveJournalDir()
+		if err != nil {
+			return err
+		}
+		journal, err = organizer.NewJournal(journalDir, options.Directory, options.DestPath)
+		if err != nil {
+			return err
+		}
+		defer journal.Close()
+		opts.Journal = journal
+	}
+
+	// Stop gracefully on Ctrl-C or SIGTERM
+	ctx, stop := signalContext()
+	defer stop()
+
+	// Start the progress display with yacspin if requested, JSON output is never mixed with it
+	var stopProgress chan struct{}
+	if options.ShowProgress && !jsonOutput {
+		opts.Stats = &organizer.Stats{}
+		stopProgress = make(chan struct{})
+		if err := utils.DisplayProgress(opts.Stats, stopProgress); err != nil {
+			return fmt.Errorf("error starting progress display: %w", err)
+		}
+	}
+
+	// Run the organization, an interrupted run still returns the stats so far
+	start := time.Now()
+	stats, err := org.Execute(ctx, opts)
+
+	// Stop the progress display if it was started
+	if stopProgress != nil {
+		close(stopProgress)
+		time.Sleep(100 * time.Millisecond) // Give time for the last progress update
+	}
+
+	// Files that could not be organized are in the stats, only options that cannot be used return
+	// none. A directory that could not be read still returns the stats of the files found before
+	if stats == nil {
+		return err
+	}
+	interrupted := ctx.Err() != nil
+
+	// Only moving files can leave empty directories behind, and only a complete walk knows them all
+	cleanup := !interrupted && !errors.Is(err, organizer.ErrWalk) && !options.DryRun && options.CleanupEmptyDirs && (options.Mode == "" || options.Mode == organizer.ModeMove)
+	// Collect the cleanup warnings with those of the run
+	opts.Stats = stats
+
+	if jsonOutput {
+		removedCount := 0
+		if cleanup {
+			var cleanupErr error
+			if removedCount, cleanupErr = org.Cleanup(opts); cleanupErr != nil {
+				return fmt.Errorf("error during cleanup: %w", cleanupErr)
+			}
+		}
+		if err := printJSONResult(newRunResult(stats, journal, removedCount, time.Since(start), interrupted)); err != nil {
+			return err
+		}
+		return runError(ctx, stats, err)
+	}
+
+	printWarnings(stats)
+	printErrors(stats)
+	printSkippedFiles(stats, options.ListSkipped)
+
+	if options.DryRun {
+		printPlannedMoves(stats)
+		return runError(ctx, stats, err)
+	}
+
 	fmt.Printf("\n\tTotal files: %d\n", stats.TotalFiles)
 	fmt.Printf("\tOrganized files: %d\n", stats.OrganizedFiles)
 	fmt.Printf("\tSkipped files: %d\n", stats.SkippedFiles)
+	printSkipReasons(stats)
+	if stats.DedupedFiles > 0 {
+		fmt.Printf("\tDuplicates removed: %d\n", stats.DedupedFiles)
+	}
+	if stats.FailedFiles > 0 {
+		fmt.Printf("\tFailed files: %d\n", stats.FailedFiles)
+	}
 
-	if options.CleanupEmptyDirs {
+	if interrupted {
+		fmt.Printf("\n\tInterrupted, the remaining files were left in place\n")
+	}
+
+	if cleanup {
 		fmt.Printf("\n\tCleaning up empty directories...\n")
-		removedCount, err := utils.CleanupEmptyDirs(options.Directory)
+		warningCount := len(stats.Warnings)
+		removedCount, err := org.Cleanup(opts)
 		if err != nil {
 			return fmt.Errorf("\terror during cleanup: %w", err)
 		}
 		fmt.Printf("\tRemoved %d empty directories\n", removedCount)
-		fmt.Println("")
+		for _, warning := range stats.Warnings[warningCount:] {
+			fmt.Printf("\t  Warning: %s\n", warning)
+		}
+	}
+
+	if journal.Entries() > 0 {
+		fmt.Printf("\n\tRun ID: %s (revert with: folder-organizer undo %s)\n", journal.RunID, journal.RunID)
 	}
+	fmt.Println("")
 
+	return runError(ctx, stats, err)
+}
+
+// errTooManyFailures is returned when more files could not be organized than --max-failures allows
+var errTooManyFailures = errors.New("too many files could not be organized")
+
+// runError returns the error an organize run ends with: the interruption, a directory that could
+// not be read, or too many failed files. err is the error the run returned
+func runError(ctx context.Context, stats *organizer.Stats, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("organization interrupted: %w", ctx.Err())
+	}
+	if errors.Is(err, organizer.ErrWalk) {
+		return err
+	}
+	return failuresError(stats)
+}
+
+// failuresError returns errTooManyFailures when more files could not be organized than
+// --max-failures allows
+func failuresError(stats *organizer.Stats) error {
+	if options.MaxFailures >= 0 && stats.FailedFiles > options.MaxFailures {
+		return fmt.Errorf("%w: %d failed, %d allowed", errTooManyFailures, stats.FailedFiles, options.MaxFailures)
+	}
 	return nil
 }
+
+// printWarnings lists the non-fatal problems found during the run
+func printWarnings(stats *organizer.Stats) {
+	if len(stats.Warnings) == 0 {
+		return
+	}
+
+	fmt.Printf("\n\tWarnings:\n")
+	for _, warning := range stats.Warnings {
+		fmt.Printf("\t  %s\n", warning)
+	}
+}
+
+// printErrors lists the files that could not be organized
+func printErrors(stats *organizer.Stats) {
+	if len(stats.Errors) == 0 {
+		return
+	}
+
+	fmt.Printf("\n\tErrors:\n")
+	for _, fileErr := range stats.Errors {
+		fmt.Printf("\t  %v\n", fileErr)
+	}
+}
+
+// printSkipReasons breaks the skipped files down by reason, most frequent first
+func printSkipReasons(stats *organizer.Stats) {
+	reasons := make([]string, 0, len(stats.SkipsByReason))
+	for reason := range stats.SkipsByReason {
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if stats.SkipsByReason[reasons[i]] != stats.SkipsByReason[reasons[j]] {
+			return stats.SkipsByReason[reasons[i]] > stats.SkipsByReason[reasons[j]]
+		}
+		return reasons[i] < reasons[j]
+	})
+
+	for _, reason := range reasons {
+		fmt.Printf("\t  %s: %d\n", reason, stats.SkipsByReason[reason])
+	}
+}
+
+// printSkippedFiles lists the files skipped for a reason, or for any reason with listSkippedAll,
+// sorted by path
+func printSkippedFiles(stats *organizer.Stats, reason string) {
+	if reason == "" {
+		return
+	}
+
+	var skips []organizer.FileSkip
+	for _, skip := range stats.Skips {
+		if reason == listSkippedAll || skip.Reason == reason {
+			skips = append(skips, skip)
+		}
+	}
+	sort.Slice(skips, func(i, j int) bool {
+		return skips[i].Path < skips[j].Path
+	})
+
+	fmt.Printf("\n\tSkipped files (%s):\n", reason)
+	if len(skips) == 0 {
+		fmt.Printf("\t  none\n")
+	}
+	for _, skip := range skips {
+		if reason == listSkippedAll {
+			fmt.Printf("\t  %s (%s)\n", skip.Path, skip.Reason)
+		} else {
+			fmt.Printf("\t  %s\n", skip.Path)
+		}
+	}
+}
+
+// printPlannedMoves lists every move a dry run would perform, sorted by source path
+func printPlannedMoves(stats *organizer.Stats) {
+	moves := stats.Moves
+	sort.Slice(moves, func(i, j int) bool {
+		return moves[i].Source < moves[j].Source
+	})
+
+	fmt.Printf("\n\tPlanned moves (dry run, nothing was changed):\n")
+	for _, move := range moves {
+		var notes []string
+		if move.Mode != "" && move.Mode != organizer.ModeMove {
+			notes = append(notes, move.Mode)
+		}
+		if move.Action == organizer.MoveActionOverwrite {
+			notes = append(notes, "overwrite")
+		}
+
+		switch {
+		case move.Action == organizer.MoveActionDedupe:
+			fmt.Printf("\t%s == %s (duplicate, delete)\n", move.Source, move.Target)
+		case len(notes) > 0:
+			fmt.Printf("\t%s -> %s (%s)\n", move.Source, move.Target, strings.Join(notes, ", "))
+		default:
+			fmt.Printf("\t%s -> %s\n", move.Source, move.Target)
+		}
+	}
+
+	fmt.Printf("\n\tTotal files: %d\n", stats.TotalFiles)
+	fmt.Printf("\tFiles to organize: %d\n", stats.OrganizedFiles)
+	fmt.Printf("\tFiles to skip: %d\n", stats.SkippedFiles)
+	printSkipReasons(stats)
+	if stats.DedupedFiles > 0 {
+		fmt.Printf("\tDuplicates to remove: %d\n", stats.DedupedFiles)
+	}
+	fmt.Println("")
+}
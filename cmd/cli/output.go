@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ondrovic/folder-organizer/pkg/organizer"
+)
+
+// Output formats of the organize command
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+var outputFormats = []string{outputText, outputJSON}
+
+// listSkippedAll lists the skipped files for every reason with --list-skipped
+const listSkippedAll = "all"
+
+// runResult is the result of an organize run, as printed by --output json
+type runResult struct {
+	RunID           string               `json:"run_id,omitempty"`
+	Source          string               `json:"source"`
+	Destination     string               `json:"destination,omitempty"`
+	DryRun          bool                 `json:"dry_run"`
+	Interrupted     bool                 `json:"interrupted"`
+	TotalFiles      int                  `json:"total_files"`
+	ProcessedFiles  int                  `json:"processed_files"`
+	OrganizedFiles  int                  `json:"organized_files"`
+	SkippedFiles    int                  `json:"skipped_files"`
+	SkippedByReason map[string]int       `json:"skipped_by_reason"`
+	DedupedFiles    int                  `json:"deduped_files"`
+	FailedFiles     int                  `json:"failed_files"`
+	RemovedDirs     int                  `json:"removed_dirs"`
+	DurationSeconds float64              `json:"duration_seconds"`
+	Categories      map[string]int       `json:"categories"`
+	Moves           []organizer.FileMove `json:"moves"`
+	Skips           []organizer.FileSkip `json:"skips"`
+	Errors          []fileErrorResult    `json:"errors"`
+	Warnings        []string             `json:"warnings"`
+}
+
+// fileErrorResult is a file that could not be organized, in a runResult
+type fileErrorResult struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// newRunResult builds the result of a run from its stats. Categories counts the files organized
+// into each category, duplicates that were removed are not included
+func newRunResult(stats *organizer.Stats, journal *organizer.Journal, removedDirs int, duration time.Duration, interrupted bool) runResult {
+	result := runResult{
+		Source:          options.Directory,
+		Destination:     options.DestPath,
+		DryRun:          options.DryRun,
+		Interrupted:     interrupted,
+		TotalFiles:      stats.TotalFiles,
+		ProcessedFiles:  stats.ProcessedFiles,
+		OrganizedFiles:  stats.OrganizedFiles,
+		SkippedFiles:    stats.SkippedFiles,
+		SkippedByReason: make(map[string]int),
+		DedupedFiles:    stats.DedupedFiles,
+		FailedFiles:     stats.FailedFiles,
+		RemovedDirs:     removedDirs,
+		DurationSeconds: duration.Seconds(),
+		Categories:      make(map[string]int),
+		// Empty lists are printed as [] rather than null
+		Moves:    append([]organizer.FileMove{}, stats.Moves...),
+		Skips:    append([]organizer.FileSkip{}, stats.Skips...),
+		Errors:   make([]fileErrorResult, 0, len(stats.Errors)),
+		Warnings: append([]string{}, stats.Warnings...),
+	}
+
+	if journal != nil && journal.Entries() > 0 {
+		result.RunID = journal.RunID
+	}
+	for _, move := range stats.Moves {
+		if move.Action != organizer.MoveActionDedupe {
+			result.Categories[move.Category]++
+		}
+	}
+	for reason, count := range stats.SkipsByReason {
+		result.SkippedByReason[reason] = count
+	}
+	for _, fileErr := range stats.Errors {
+		result.Errors = append(result.Errors, fileErrorResult{Path: fileErr.Path, Error: fileErr.Err.Error()})
+	}
+
+	return result
+}
+
+// printJSONResult writes a run result to stdout as indented JSON
+func printJSONResult(result runResult) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		return fmt.Errorf("error writing result: %w", err)
+	}
+	return nil
+}
@@ -1,7 +1,17 @@
 package cli
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+
+	sCli "github.com/ondrovic/common/utils/cli"
 	"github.com/ondrovic/folder-organizer/internal/types"
+	"github.com/ondrovic/folder-organizer/pkg/organizer"
 
 	"github.com/spf13/cobra"
 )
@@ -11,11 +21,53 @@ var (
 	RootCmd = &cobra.Command{
 		Use:   "folder-organizer",
 		Short: "A Cli tool to organize files in a folder",
+		// Clear the terminal before a command runs, unless its output is meant for other programs
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if options.Output == outputJSON {
+				return nil
+			}
+			return sCli.ClearTerminalScreen(runtime.GOOS)
+		},
 	}
 )
 
 func InitializeCommands() {
 	RootCmd.AddCommand(organizeCmd)
+	RootCmd.AddCommand(undoCmd)
+	RootCmd.AddCommand(validateCmd)
+	RootCmd.AddCommand(watchCmd)
+}
+
+// resolveJournalDir returns the journal directory from the flags or the default location
+func resolveJournalDir() (string, error) {
+	if options.JournalDir != "" {
+		return options.JournalDir, nil
+	}
+	return organizer.DefaultJournalDir()
+}
+
+// newOrganizer loads the configuration file from the flags and builds an organizer from it
+func newOrganizer() (*organizer.Organizer, error) {
+	config, err := organizer.LoadConfig(options.ConfigurationPath, options.ConfigFormat)
+	if err != nil {
+		return nil, fmt.Errorf("error loading config: %w", err)
+	}
+	return organizer.New(config)
+}
+
+// Exit statuses of the process
+const (
+	exitError    = 1
+	exitFailures = 2
+)
+
+// ExitCode returns the exit status for an error returned by a command: 2 when files could not be
+// organized, 1 for any other error
+func ExitCode(err error) int {
+	if errors.Is(err, errTooManyFailures) {
+		return exitFailures
+	}
+	return exitError
 }
 
 func Execute() error {
@@ -25,3 +77,14 @@ func Execute() error {
 
 	return nil
 }
+
+// signalContext returns a context that is cancelled on the first Ctrl-C or SIGTERM. After that
+// the default signal handling is restored, so a second Ctrl-C exits immediately
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
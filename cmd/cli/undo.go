@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/ondrovic/folder-organizer/pkg/organizer"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	undoCmd = &cobra.Command{
+		Use:   "undo [run-id]",
+		Short: "Revert an organize run using its journal",
+		Long: `Revert an organize run by moving every file back to where it was (or removing the
+copies and links it created) and recreating the directories removed during cleanup. Without a run ID the most recent run is undone.
+Files that were modified, moved or deleted since the run are left alone and reported.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runUndo,
+	}
+)
+
+func init() {
+	undoCmd.Flags().BoolVarP(&options.ListRuns, "list", "l", false, "List journaled runs instead of undoing one")
+	undoCmd.Flags().StringVar(&options.JournalDir, "journal-dir", "", "Directory where run journals are stored (default: user config directory)")
+}
+
+func runUndo(cmd *cobra.Command, args []string) error {
+	// Arguments are valid, runtime errors should not print the usage
+	cmd.SilenceUsage = true
+
+	journalDir, err := resolveJournalDir()
+	if err != nil {
+		return err
+	}
+
+	if options.ListRuns {
+		return listRuns(journalDir)
+	}
+
+	runID := ""
+	if len(args) > 0 {
+		runID = args[0]
+	}
+
+	result, err := organizer.UndoRun(journalDir, runID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n\tUndid run %s\n", result.RunID)
+	fmt.Printf("\tRestored files: %d\n", result.RestoredFiles)
+	if result.RemovedFiles > 0 {
+		fmt.Printf("\tRemoved copies and links: %d\n", result.RemovedFiles)
+	}
+	fmt.Printf("\tRecreated directories: %d\n", result.RecreatedDirs)
+	printUndoProblems("Changed since the run (left in place)", result.Changed)
+	printUndoProblems("Missing", result.Missing)
+	printUndoProblems("Original path already taken", result.Conflicts)
+	printUndoProblems("Failed to restore", result.Failed)
+	printUndoProblems("Restored, but the file they overwrote cannot be recovered", result.Replaced)
+	if len(result.Errors) > 0 {
+		fmt.Printf("\n\tErrors: %d\n", len(result.Errors))
+		for _, err := range result.Errors {
+			fmt.Printf("\t  %v\n", err)
+		}
+	}
+	if !result.Complete() {
+		fmt.Printf("\n\tThe run was only partly undone, run undo %s again once the problems above are fixed\n", result.RunID)
+	}
+	fmt.Println("")
+
+	return nil
+}
+
+// listRuns prints every journaled run, newest first
+func listRuns(journalDir string) error {
+	runs, err := organizer.ListRuns(journalDir)
+	if err != nil {
+		return err
+	}
+
+	if len(runs) == 0 {
+		fmt.Printf("\n\tNo journaled runs in %s\n\n", journalDir)
+		return nil
+	}
+
+	fmt.Println("")
+	for _, run := range runs {
+		status := ""
+		if run.Undone {
+			status = " (undone)"
+		} else if run.PartlyUndone {
+			status = " (partly undone)"
+		}
+		fmt.Printf("\t%s\t%d moves\t%s%s\n", run.RunID, run.Moves, run.Path, status)
+	}
+	fmt.Println("")
+
+	return nil
+}
+
+func printUndoProblems(title string, paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	fmt.Printf("\n\t%s: %d\n", title, len(paths))
+	for _, path := range paths {
+		fmt.Printf("\t  %s\n", path)
+	}
+}
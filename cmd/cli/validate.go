@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/ondrovic/folder-organizer/pkg/organizer"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	validateCmd = &cobra.Command{
+		Use:   "validate <config-file-path>",
+		Short: "Check a configuration file for conflicts and mistakes",
+		Long: `Validate a configuration file without organizing anything. Reports extensions mapped
+to more than one category (and which one takes precedence), empty categories, malformed
+entries that would be ignored and category names that cannot be used as folder names.
+Exits with a non-zero status when errors are found (or warnings, with --strict), so it
+can be used to gate config changes.`,
+		Args:         cobra.ExactArgs(1),
+		RunE:         runValidate,
+		SilenceUsage: true,
+	}
+)
+
+func init() {
+	validateCmd.Flags().StringVar(&options.ConfigFormat, "config-format", "", "Config file format: json, yaml or toml (default: detected from the file extension)")
+	validateCmd.Flags().BoolVar(&options.Strict, "strict", false, "Treat warnings, such as duplicate extensions, as errors")
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	options.ConfigurationPath = args[0]
+
+	issues, err := organizer.ValidateConfig(options.ConfigurationPath, options.ConfigFormat)
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+
+	errorCount := 0
+	warningCount := 0
+	fmt.Println("")
+	for _, issue := range issues {
+		if issue.Severity == organizer.IssueError {
+			errorCount++
+		} else {
+			warningCount++
+		}
+
+		category := issue.Category
+		if category == "" {
+			category = "config"
+		}
+		fmt.Printf("\t%s: [%s] %s\n", issue.Severity, category, issue.Message)
+	}
+
+	if len(issues) == 0 {
+		fmt.Printf("\t%s is valid\n\n", options.ConfigurationPath)
+		return nil
+	}
+
+	fmt.Printf("\n\tErrors: %d\n", errorCount)
+	fmt.Printf("\tWarnings: %d\n", warningCount)
+	fmt.Println("")
+
+	if errorCount > 0 {
+		return fmt.Errorf("%s has %d error(s)", options.ConfigurationPath, errorCount)
+	}
+	if options.Strict && warningCount > 0 {
+		return fmt.Errorf("%s has %d warning(s)", options.ConfigurationPath, warningCount)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ondrovic/folder-organizer/pkg/organizer"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchCmd = &cobra.Command{
+		Use:   "watch <config-file-path> <folder-to-watch>",
+		Short: "Organize files as they arrive in the specified folder",
+		Long: `Watch a folder and organize new files as they arrive, using the same configuration
+as the organize command. A file is only moved once it has not been written to for the
+settle delay, so downloads and copies in progress are left alone. Stop watching with
+Ctrl-C (or SIGTERM); files being moved are finished (or rolled back) before exiting.`,
+		Args: cobra.ExactArgs(2),
+		RunE: runWatch,
+	}
+)
+
+func init() {
+	watchCmd.Flags().IntVarP(&options.NumOfWorkers, "workers", "w", 4, "Number of worker goroutines")
+	watchCmd.Flags().BoolVarP(&options.Recursive, "recursive", "r", true, "Watch subdirectories recursively")
+	watchCmd.Flags().DurationVar(&options.SettleDelay, "settle", 5*time.Second, "How long a file must be unchanged before it is organized")
+	watchCmd.Flags().BoolVar(&options.ScanExisting, "existing", false, "Also organize the files already in the folder when watching starts")
+	watchCmd.Flags().StringVarP(&options.DestPath, "dest", "d", "", "Move organized files into this directory instead of organizing in place")
+	watchCmd.Flags().StringVarP(&options.Mode, "mode", "m", organizer.ModeMove, "How files are placed in their target folder: "+strings.Join(organizer.Modes, ", "))
+	watchCmd.Flags().StringVar(&options.OnConflict, "on-conflict", organizer.ConflictRename, "What to do when the target file exists: "+strings.Join(organizer.ConflictStrategies, ", "))
+	watchCmd.Flags().BoolVar(&options.DetectContent, "detect-content", false, "Classify files by their content when the name does not match or the content contradicts the extension")
+	watchCmd.Flags().StringArrayVar(&options.Exclude, "exclude", nil, "Gitignore-style pattern of files and directories to leave alone (repeatable)")
+	watchCmd.Flags().StringVar(&options.ConfigFormat, "config-format", "", "Config file format: json, yaml or toml (default: detected from the file extension)")
+	watchCmd.Flags().IntVar(&options.MaxFailures, "max-failures", 0, "Number of files that may fail to be organized before exiting with status 2, negative to never fail")
+	watchCmd.Flags().StringVar(&options.JournalDir, "journal-dir", "", "Directory where run journals are stored (default: user config directory)")
+}
+
+func runWatch(cmd *cobra.Command, args []string) error {
+	// Arguments are valid, runtime errors should not print the usage
+	cmd.SilenceUsage = true
+
+	options.ConfigurationPath = args[0]
+	options.Directory = args[1]
+
+	// Stop watching on Ctrl-C or SIGTERM
+	ctx, stop := signalContext()
+	defer stop()
+
+	org, err := newOrganizer()
+	if err != nil {
+		return err
+	}
+
+	opts := organizer.Options{
+		SourcePath:    options.Directory,
+		DestPath:      options.DestPath,
+		NumWorkers:    options.NumOfWorkers,
+		Recursive:     options.Recursive,
+		OnConflict:    options.OnConflict,
+		Mode:          options.Mode,
+		DetectContent: options.DetectContent,
+		Exclude:       options.Exclude,
+		OnMove:        printMove,
+		OnError:       printWatchError,
+	}
+
+	// Journal every change so the session can be undone
+	journalDir, err := resolveJournalDir()
+	if err != nil {
+		return err
+	}
+	journal, err := organizer.NewJournal(journalDir, options.Directory, options.DestPath)
+	if err != nil {
+		return err
+	}
+	defer journal.Close()
+	opts.Journal = journal
+
+	fmt.Printf("\n\tWatching %s (press Ctrl-C to stop)\n\n", options.Directory)
+
+	stats, err := org.Watch(ctx, opts, organizer.WatchOptions{
+		Settle:       options.SettleDelay,
+		ScanExisting: options.ScanExisting,
+	})
+	// Files that could not be organized are in the stats, only a watch that could not start
+	// returns none
+	if stats == nil {
+		return err
+	}
+
+	printWarnings(stats)
+	printErrors(stats)
+
+	fmt.Printf("\n\tTotal files: %d\n", stats.TotalFiles)
+	fmt.Printf("\tOrganized files: %d\n", stats.OrganizedFiles)
+	fmt.Printf("\tSkipped files: %d\n", stats.SkippedFiles)
+	printSkipReasons(stats)
+	if stats.DedupedFiles > 0 {
+		fmt.Printf("\tDuplicates removed: %d\n", stats.DedupedFiles)
+	}
+	if stats.FailedFiles > 0 {
+		fmt.Printf("\tFailed files: %d\n", stats.FailedFiles)
+	}
+
+	if journal.Entries() > 0 {
+		fmt.Printf("\n\tRun ID: %s (revert with: folder-organizer undo %s)\n", journal.RunID, journal.RunID)
+	}
+	fmt.Println("")
+
+	// Stopping is how a watch ends, only the failed files decide the exit status
+	return failuresError(stats)
+}
+
+// printMove reports a file organized while watching
+func printMove(move organizer.FileMove) {
+	if move.Action == organizer.MoveActionDedupe {
+		fmt.Printf("\t%s %s == %s (duplicate, deleted)\n", time.Now().Format("15:04:05"), move.Source, move.Target)
+		return
+	}
+	fmt.Printf("\t%s %s -> %s\n", time.Now().Format("15:04:05"), move.Source, move.Target)
+}
+
+// printWatchError reports a problem met while watching
+func printWatchError(err error) {
+	fmt.Printf("\t%s Error: %v\n", time.Now().Format("15:04:05"), err)
+}
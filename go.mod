@@ -3,10 +3,14 @@ module github.com/ondrovic/folder-organizer
 go 1.24.1
 
 require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/fsnotify/fsnotify v1.10.1
 	github.com/ondrovic/common v0.1.24
 	github.com/spf13/cobra v1.9.1
 	github.com/theckman/yacspin v0.13.12
 	go.szostok.io/version v1.2.0
+	golang.org/x/text v0.17.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -47,7 +51,5 @@ require (
 	golang.org/x/crypto v0.7.0 // indirect
 	golang.org/x/sys v0.24.0 // indirect
 	golang.org/x/term v0.23.0 // indirect
-	golang.org/x/text v0.17.0 // indirect
 	golang.org/x/xerrors v0.0.0-20220907171357-04be3eba64a2 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/ondrovic/folder-organizer/internal/types"
+	"github.com/ondrovic/folder-organizer/pkg/organizer"
 	"github.com/theckman/yacspin"
 )
 
@@ -30,21 +30,22 @@ func NewProgressSpinner() (*yacspin.Spinner, error) {
 }
 
 // UpdateProgress updates the spinner with the current progress information
-func UpdateProgress(spinner *yacspin.Spinner, stats *types.Stats) {
-	if stats.TotalFiles > 0 {
-		percentage := float64(stats.ProcessedFiles) / float64(stats.TotalFiles) * 100
+func UpdateProgress(spinner *yacspin.Spinner, stats *organizer.Stats) {
+	progress := stats.Snapshot()
+	if progress.TotalFiles > 0 {
+		percentage := float64(progress.ProcessedFiles) / float64(progress.TotalFiles) * 100
 		message := fmt.Sprintf("%d/%d files (%.1f%%) | Organized: %d | Skipped: %d",
-			stats.ProcessedFiles, stats.TotalFiles, percentage, stats.OrganizedFiles, stats.SkippedFiles)
+			progress.ProcessedFiles, progress.TotalFiles, percentage, progress.OrganizedFiles, progress.SkippedFiles)
 		spinner.Message(message)
 	} else {
 		message := fmt.Sprintf("%d files | Organized: %d | Skipped: %d",
-			stats.ProcessedFiles, stats.OrganizedFiles, stats.SkippedFiles)
+			progress.ProcessedFiles, progress.OrganizedFiles, progress.SkippedFiles)
 		spinner.Message(message)
 	}
 }
 
 // DisplayProgress starts a spinner and continuously updates it with progress information
-func DisplayProgress(stats *types.Stats, stop <-chan struct{}) error {
+func DisplayProgress(stats *organizer.Stats, stop <-chan struct{}) error {
 	spinner, err := NewProgressSpinner()
 	if err != nil {
 		return err
@@ -60,8 +61,9 @@ func DisplayProgress(stats *types.Stats, stop <-chan struct{}) error {
 		for {
 			select {
 			case <-stop:
+				progress := stats.Snapshot()
 				spinner.Message(fmt.Sprintf("Completed! Processed: %d | Organized: %d | Skipped: %d",
-					stats.ProcessedFiles, stats.OrganizedFiles, stats.SkippedFiles))
+					progress.ProcessedFiles, progress.OrganizedFiles, progress.SkippedFiles))
 				return
 			case <-time.After(500 * time.Millisecond):
 				UpdateProgress(spinner, stats)
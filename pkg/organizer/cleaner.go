@@ -0,0 +1,114 @@
+package organizer
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+// Cleanup removes the directories a run with opts left empty in the source directory, keeping
+// the destination root and ignored directories. Removed directories are recorded in opts.Journal,
+// and problems are recorded as warnings on opts.Stats, such as the Stats returned by Execute.
+// Nothing is removed in dry-run mode
+func (o *Organizer) Cleanup(opts Options) (int, error) {
+	if opts.DryRun {
+		return 0, nil
+	}
+	fsys := opts.FS
+	if fsys == nil {
+		fsys = OSFS{}
+	}
+	ignores, err := o.newIgnoreMatcher(fsys, opts.SourcePath, opts.Exclude, opts.Stats)
+	if err != nil {
+		return 0, err
+	}
+	return cleanupEmptyDirs(fsys, opts.SourcePath, opts.Journal, ignores, opts.Stats, opts.DestPath)
+}
+
+// CleanupEmptyDirs removes all empty directories in the specified path
+// It works recursively from the bottom up to ensure nested empty directories are properly removed.
+// Removed directories are recorded in the journal when one is given, so an undo can recreate them,
+// and problems are recorded as warnings on stats when it is not nil.
+// Directories listed in keep (such as a destination root inside rootPath) are left untouched
+func CleanupEmptyDirs(fsys FileSystem, rootPath string, journal JournalWriter, stats *Stats, keep ...string) (int, error) {
+	return cleanupEmptyDirs(fsys, rootPath, journal, nil, stats, keep...)
+}
+
+// cleanupEmptyDirs implements CleanupEmptyDirs, leaving the directories ignores matches untouched
+// when it is not nil
+func cleanupEmptyDirs(fsys FileSystem, rootPath string, journal JournalWriter, ignores *ignoreMatcher, stats *Stats, keep ...string) (int, error) {
+	removedCount := 0
+
+	// Normalize the path to handle spaces and special characters
+	rootPath = filepath.Clean(rootPath)
+
+	keepDirs := make(map[string]bool, len(keep))
+	for _, dir := range keep {
+		if dir != "" {
+			keepDirs[absPath(dir)] = true
+		}
+	}
+
+	// This function will be called for each directory after its contents have been processed
+	removeIfEmpty := func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// fmt.Printf("Warning: Error accessing path: %v\n", err)
+			return filepath.SkipDir
+		}
+
+		// Skip if not a directory or if it's the root path
+		if !d.IsDir() || filepath.Clean(path) == rootPath {
+			return nil
+		}
+
+		// Never clean up directories that should be kept, nor anything inside them
+		if keepDirs[absPath(path)] || (ignores != nil && ignores.ignored(path, true)) {
+			return filepath.SkipDir
+		}
+
+		// Check if directory is empty
+		entries, err := fsys.ReadDir(path)
+		if err != nil {
+			// fmt.Printf("Warning: Error reading directory %s: %v\n", path, err)
+			return filepath.SkipDir
+		}
+
+		if len(entries) == 0 {
+			// Directory is empty, remove it
+			if err := fsys.Remove(path); err != nil {
+				// fmt.Printf("Warning: Error removing empty directory %s: %v\n", path, err)
+				return nil // Continue with other directories
+			}
+			removedCount++
+
+			if journal != nil {
+				if err := journal.RecordRemovedDir(path); err != nil && stats != nil {
+					stats.AddWarning(fmt.Sprintf("error recording removed directory %s: %v", path, err))
+				}
+			}
+		}
+
+		return nil
+	}
+
+	// Walk the directory tree from the bottom up (to handle nested empty directories)
+	// We need to do multiple passes because we might create new empty directories
+	// after removing subdirectories
+	for {
+		currentRemovedCount := removedCount
+
+		// Do a walkdir to actually remove empty directories
+		err := fsys.WalkDir(rootPath, removeIfEmpty)
+		if err != nil {
+			// fmt.Printf("Warning: Some errors occurred during cleanup, but continuing: %v\n", err)
+			// Continue despite errors
+		}
+
+		// If we didn't remove any directories in this pass, we're done
+		if removedCount == currentRemovedCount {
+			break
+		}
+	}
+
+	return removedCount, nil
+}
@@ -0,0 +1,602 @@
+package organizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Supported configuration file formats
+const (
+	ConfigFormatJSON = "json"
+	ConfigFormatYAML = "yaml"
+	ConfigFormatTOML = "toml"
+)
+
+// detectConfigFormat returns the configuration format, using the explicit format when given
+// and the file extension otherwise. Unknown extensions are treated as JSON
+func detectConfigFormat(path, format string) (string, error) {
+	if format != "" {
+		switch strings.ToLower(format) {
+		case ConfigFormatJSON:
+			return ConfigFormatJSON, nil
+		case ConfigFormatYAML, "yml":
+			return ConfigFormatYAML, nil
+		case ConfigFormatTOML:
+			return ConfigFormatTOML, nil
+		default:
+			return "", fmt.Errorf("unsupported config format %q (expected json, yaml or toml)", format)
+		}
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ConfigFormatYAML, nil
+	case ".toml":
+		return ConfigFormatTOML, nil
+	default:
+		return ConfigFormatJSON, nil
+	}
+}
+
+// configToJSON converts YAML and TOML configuration data to JSON, so every format is parsed
+// into the same category tree. Key order is preserved wherever the source format allows it
+func configToJSON(data []byte, format string) ([]byte, error) {
+	switch format {
+	case ConfigFormatYAML:
+		return yamlToJSON(data)
+	case ConfigFormatTOML:
+		return tomlToJSON(data)
+	default:
+		return data, nil
+	}
+}
+
+// LoadConfig loads and parses a configuration file. The format is one of ConfigFormatJSON,
+// ConfigFormatYAML or ConfigFormatTOML, and is detected from the file extension when empty
+func LoadConfig(path, format string) (*Config, error) {
+	format, err := detectConfigFormat(path, format)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseConfig(data, format)
+}
+
+// ParseConfig parses configuration data in the given format. YAML and TOML are converted to
+// JSON first, so all formats produce the same category tree
+func ParseConfig(data []byte, format string) (*Config, error) {
+	format, err := detectConfigFormat("", format)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonData, err := configToJSON(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", format, err)
+	}
+
+	var config Config
+	err = json.Unmarshal(jsonData, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remember the order the categories are declared in, maps do not keep it
+	var rawConfig struct {
+		Categories json.RawMessage `json:"categories"`
+	}
+	if err := json.Unmarshal(jsonData, &rawConfig); err == nil && len(rawConfig.Categories) > 0 {
+		config.CategoryOrder, _ = objectKeys(rawConfig.Categories)
+	}
+
+	return &config, nil
+}
+
+// parseCategory processes a category entry from the JSON
+func parseCategory(data json.RawMessage) (*Category, error) {
+	category := &Category{
+		Extensions:    []string{},
+		Subcategories: make(map[string]*Category),
+	}
+
+	// Try to unmarshal as array of strings first (simple extension list)
+	var extensions []string
+	if err := json.Unmarshal(data, &extensions); err == nil {
+		category.Extensions = extensions
+		return category, nil
+	}
+
+	// Try to unmarshal as array of objects (may contain both subcategories and extensions)
+	var objArray []json.RawMessage
+	if err := json.Unmarshal(data, &objArray); err == nil {
+		for _, item := range objArray {
+			// Try as string array first (for extension lists inside arrays)
+			var strArray []string
+			if err := json.Unmarshal(item, &strArray); err == nil {
+				category.Extensions = append(category.Extensions, strArray...)
+				continue
+			}
+
+			// Try as map (for subcategories)
+			var objMap map[string]json.RawMessage
+			if err := json.Unmarshal(item, &objMap); err == nil {
+				if err := parseSubcategories(category, item, objMap); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+		return category, nil
+	}
+
+	// Try to unmarshal as object (subcategories)
+	var objMap map[string]json.RawMessage
+	if err := json.Unmarshal(data, &objMap); err == nil {
+		if err := parseSubcategories(category, data, objMap); err != nil {
+			return nil, err
+		}
+		return category, nil
+	}
+
+	return nil, fmt.Errorf("unable to parse category data")
+}
+
+// parseSubcategories adds the subcategories of a JSON object to category, keeping their declaration order
+func parseSubcategories(category *Category, data json.RawMessage, objMap map[string]json.RawMessage) error {
+	names, err := objectKeys(data)
+	if err != nil {
+		return err
+	}
+
+	for _, subName := range names {
+		subCategory, err := parseCategory(objMap[subName])
+		if err != nil {
+			return fmt.Errorf("error parsing subcategory %s: %w", subName, err)
+		}
+		if _, exists := category.Subcategories[subName]; !exists {
+			category.SubcategoryOrder = append(category.SubcategoryOrder, subName)
+		}
+		category.Subcategories[subName] = subCategory
+	}
+
+	return nil
+}
+
+// buildExtensionMapping converts the nested category structure to a flat mapping of extensions to paths.
+// When an extension is listed more than once, the last declaration wins: categories are applied in the
+// order they appear in the config, and a category's own extensions are applied before its subcategories.
+// Every override is recorded so it can be reported
+func buildExtensionMapping(config *Config) (*ExtensionMapping, error) {
+	mapping := &ExtensionMapping{
+		ExtToPath:  make(map[string]string),
+		MIMEToPath: make(map[string]string),
+	}
+
+	for _, topName := range categoryNames(config) {
+		category, err := parseCategory(config.Categories[topName])
+		if err != nil {
+			return nil, fmt.Errorf("error parsing top-level category %s: %w", topName, err)
+		}
+
+		// Process the top-level extensions
+		if err := addExtensions(mapping, topName, category.Extensions); err != nil {
+			return nil, err
+		}
+
+		// Process subcategories recursively
+		if err := processSubcategories(mapping, topName, category); err != nil {
+			return nil, err
+		}
+	}
+
+	return mapping, nil
+}
+
+// categoryNames returns the top-level category names in declaration order. Categories missing from
+// the recorded order (e.g. a config built in code) follow in alphabetical order
+func categoryNames(config *Config) []string {
+	names := make([]string, 0, len(config.Categories))
+	seen := make(map[string]bool, len(config.Categories))
+	for _, name := range config.CategoryOrder {
+		if _, exists := config.Categories[name]; exists && !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+
+	var rest []string
+	for name := range config.Categories {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+
+	return append(names, rest...)
+}
+
+// processSubcategories recursively processes nested categories and builds the extension mapping
+func processSubcategories(mapping *ExtensionMapping, parentPath string, category *Category) error {
+	for _, subName := range subcategoryNames(category) {
+		subCat := category.Subcategories[subName]
+		currentPath := filepath.Join(parentPath, subName)
+
+		// Process extensions in this subcategory
+		if err := addExtensions(mapping, currentPath, subCat.Extensions); err != nil {
+			return err
+		}
+
+		// Process deeper subcategories
+		if err := processSubcategories(mapping, currentPath, subCat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subcategoryNames returns the subcategory names in declaration order, with any missing from the
+// recorded order following alphabetically
+func subcategoryNames(category *Category) []string {
+	names := make([]string, 0, len(category.Subcategories))
+	seen := make(map[string]bool, len(category.Subcategories))
+	for _, name := range category.SubcategoryOrder {
+		if _, exists := category.Subcategories[name]; exists && !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+
+	var rest []string
+	for name := range category.Subcategories {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+
+	return append(names, rest...)
+}
+
+// addExtensions maps each extension to path, recording an override when another category had it.
+// Entries with a "glob:" or "regex:" prefix are added as name patterns, and entries with a "mime:"
+// prefix as content types, instead
+func addExtensions(mapping *ExtensionMapping, path string, extensions []string) error {
+	for _, ext := range extensions {
+		if kind, pattern, ok := parsePattern(ext); ok {
+			if err := addPattern(mapping, path, kind, pattern); err != nil {
+				return err
+			}
+			continue
+		}
+		if mediaType, ok := strings.CutPrefix(ext, mimePrefix); ok {
+			if err := addMIMEType(mapping, path, mediaType); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Ensure extension starts with a dot
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+
+		if previous, exists := mapping.ExtToPath[ext]; exists && previous != path {
+			mapping.Overrides = append(mapping.Overrides, ExtensionOverride{
+				Extension:    ext,
+				PreviousPath: previous,
+				Path:         path,
+			})
+		}
+		mapping.ExtToPath[ext] = path
+	}
+	return nil
+}
+
+// parsePattern splits a "glob:" or "regex:" entry of an extension list into its kind and pattern
+func parsePattern(entry string) (kind, pattern string, ok bool) {
+	kind, pattern, found := strings.Cut(entry, ":")
+	if !found || (kind != PatternGlob && kind != PatternRegex) {
+		return "", "", false
+	}
+	return kind, pattern, true
+}
+
+// compilePattern checks a name pattern, returning the compiled expression of regex patterns
+func compilePattern(kind, pattern string) (*regexp.Regexp, error) {
+	if kind == PatternRegex {
+		return regexp.Compile(pattern)
+	}
+	if pattern == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// addPattern appends a name pattern, recording an override when another category declared it
+func addPattern(mapping *ExtensionMapping, path, kind, pattern string) error {
+	re, err := compilePattern(kind, pattern)
+	if err != nil {
+		return fmt.Errorf("invalid %s pattern %q in %s: %w", kind, pattern, path, err)
+	}
+
+	for _, previous := range mapping.Patterns {
+		if previous.Kind == kind && previous.Pattern == pattern && previous.Path != path {
+			mapping.Overrides = append(mapping.Overrides, ExtensionOverride{
+				Extension:    kind + ":" + pattern,
+				PreviousPath: previous.Path,
+				Path:         path,
+			})
+		}
+	}
+	mapping.Patterns = append(mapping.Patterns, NamePattern{Kind: kind, Pattern: pattern, Path: path, regexp: re})
+	return nil
+}
+
+// Match returns the directory a file is organized into, along with the extension naming its
+// extension folder. Name patterns take precedence over extensions, and when several patterns
+// match the last one declared wins. Extensions match the longest suffix of the name, so .tar.gz
+// takes precedence over .gz. The extension is empty when a pattern matched a file without one
+func (m *ExtensionMapping) Match(name string) (path, ext string, ok bool) {
+	lowerName := strings.ToLower(name)
+	ext, path, ok = m.matchExtension(lowerName)
+
+	if patternPath, matched := m.matchPattern(name, lowerName); matched {
+		if !ok {
+			ext = strings.ToLower(filepath.Ext(name))
+		}
+		return patternPath, ext, true
+	}
+
+	return path, ext, ok
+}
+
+// matchPattern returns the directory of the last declared name pattern matching the file name
+func (m *ExtensionMapping) matchPattern(name, lowerName string) (path string, ok bool) {
+	for i := len(m.Patterns) - 1; i >= 0; i-- {
+		if m.Patterns[i].matches(name, lowerName) {
+			return m.Patterns[i].Path, true
+		}
+	}
+	return "", false
+}
+
+// matchExtension returns the longest mapped extension the lowercase name ends with
+func (m *ExtensionMapping) matchExtension(lowerName string) (ext, path string, ok bool) {
+	for i := 0; i < len(lowerName); i++ {
+		if lowerName[i] != '.' {
+			continue
+		}
+		if path, ok := m.ExtToPath[lowerName[i:]]; ok {
+			return lowerName[i:], path, true
+		}
+	}
+	return "", "", false
+}
+
+// matches reports whether a file name matches the pattern
+func (p *NamePattern) matches(name, lowerName string) bool {
+	if p.Kind == PatternRegex {
+		if p.regexp == nil {
+			// Patterns built in code are compiled on every match
+			matched, _ := regexp.MatchString(p.Pattern, name)
+			return matched
+		}
+		return p.regexp.MatchString(name)
+	}
+	matched, _ := filepath.Match(strings.ToLower(p.Pattern), lowerName)
+	return matched
+}
+
+// orderedObject is a JSON object that keeps its keys in declaration order when marshalled
+type orderedObject struct {
+	keys   []string
+	values map[string]any
+}
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		keyJSON, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		valueJSON, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(keyJSON)
+		buf.WriteByte(':')
+		buf.Write(valueJSON)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// yamlToJSON converts a YAML document to JSON
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return []byte("{}"), nil
+	}
+
+	value, err := yamlNodeValue(doc.Content[0])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// yamlNodeValue converts a YAML node to a value that marshals to equivalent JSON
+func yamlNodeValue(node *yaml.Node) (any, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return yamlNodeValue(node.Content[0])
+	case yaml.AliasNode:
+		return yamlNodeValue(node.Alias)
+	case yaml.MappingNode:
+		object := orderedObject{values: make(map[string]any)}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			value, err := yamlNodeValue(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := object.values[key]; !exists {
+				object.keys = append(object.keys, key)
+			}
+			object.values[key] = value
+		}
+		return object, nil
+	case yaml.SequenceNode:
+		items := make([]any, 0, len(node.Content))
+		for _, child := range node.Content {
+			value, err := yamlNodeValue(child)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		return items, nil
+	default:
+		var value any
+		if err := node.Decode(&value); err != nil {
+			return nil, fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		return value, nil
+	}
+}
+
+// tomlToJSON converts a TOML document to JSON. Table keys keep the order in which they are
+// defined; keys of inline tables inside arrays, which TOML does not report, are sorted
+func tomlToJSON(data []byte) ([]byte, error) {
+	var raw map[string]any
+	meta, err := toml.Decode(string(data), &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make(map[string]int)
+	for i, key := range meta.Keys() {
+		path := strings.Join(key, "\x00")
+		if _, exists := positions[path]; !exists {
+			positions[path] = i
+		}
+	}
+
+	return json.Marshal(tomlValue(raw, nil, positions))
+}
+
+// tomlValue converts a decoded TOML value to a value that marshals to equivalent JSON
+func tomlValue(value any, path []string, positions map[string]int) any {
+	switch v := value.(type) {
+	case map[string]any:
+		object := orderedObject{values: make(map[string]any, len(v))}
+		for key, child := range v {
+			object.keys = append(object.keys, key)
+			object.values[key] = tomlValue(child, append(path[:len(path):len(path)], key), positions)
+		}
+		position := func(key string) (int, bool) {
+			pos, ok := positions[strings.Join(append(path[:len(path):len(path)], key), "\x00")]
+			return pos, ok
+		}
+		sort.Slice(object.keys, func(i, j int) bool {
+			pi, oki := position(object.keys[i])
+			pj, okj := position(object.keys[j])
+			if oki != okj {
+				return oki
+			}
+			if oki && pi != pj {
+				return pi < pj
+			}
+			return object.keys[i] < object.keys[j]
+		})
+		return object
+	case []map[string]any:
+		items := make([]any, 0, len(v))
+		for _, child := range v {
+			items = append(items, tomlValue(child, path, positions))
+		}
+		return items
+	case []any:
+		items := make([]any, 0, len(v))
+		for _, child := range v {
+			items = append(items, tomlValue(child, path, positions))
+		}
+		return items
+	default:
+		return v
+	}
+}
+
+// objectKeys returns the keys of a JSON object in the order they are declared
+func objectKeys(data json.RawMessage) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+
+	var keys []string
+	seen := make(map[string]bool)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := token.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected an object key")
+		}
+
+		// Skip over the value
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+
+		if !seen[key] {
+			keys = append(keys, key)
+			seen[key] = true
+		}
+	}
+
+	return keys, nil
+}
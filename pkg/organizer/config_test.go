@@ -0,0 +1,274 @@
+package organizer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// The same configuration in every supported format. Categories are declared out of alphabetical
+// order, and .png is claimed twice, so both the order and the override it causes can be compared
+var configFormats = []struct {
+	name   string
+	format string
+	data   string
+}{
+	{
+		name:   "config.json",
+		format: ConfigFormatJSON,
+		data: `{
+  "categories": {
+    "videos": [".mp4", ".mkv"],
+    "images": {
+      "raw": [".cr2", ".nef"],
+      "web": [".png", ".gif"]
+    },
+    "documents": [[".pdf"], {"office": [".docx", ".xlsx"]}],
+    "screenshots": [".png", "glob:Screenshot*"]
+  },
+  "path_template": "{category}/{ext}",
+  "ignore": ["*.tmp", "!keep.tmp"],
+  "rename": {"lowercase_ext": true}
+}`,
+	},
+	{
+		name:   "config.yaml",
+		format: ConfigFormatYAML,
+		data: `categories:
+  videos: [".mp4", ".mkv"]
+  images:
+    raw: [".cr2", ".nef"]
+    web: [".png", ".gif"]
+  documents:
+    - [".pdf"]
+    - office: [".docx", ".xlsx"]
+  screenshots: [".png", "glob:Screenshot*"]
+path_template: "{category}/{ext}"
+ignore: ["*.tmp", "!keep.tmp"]
+rename:
+  lowercase_ext: true
+`,
+	},
+	{
+		name:   "config.toml",
+		format: ConfigFormatTOML,
+		data: `path_template = "{category}/{ext}"
+ignore = ["*.tmp", "!keep.tmp"]
+
+[categories]
+videos = [".mp4", ".mkv"]
+images = { raw = [".cr2", ".nef"], web = [".png", ".gif"] }
+documents = [[".pdf"], { office = [".docx", ".xlsx"] }]
+screenshots = [".png", "glob:Screenshot*"]
+
+[rename]
+lowercase_ext = true
+`,
+	},
+}
+
+func TestLoadConfigFormatsAgree(t *testing.T) {
+	wantOrder := []string{"videos", "images", "documents", "screenshots"}
+	wantExtensions := map[string]string{
+		".mp4":  "videos",
+		".mkv":  "videos",
+		".cr2":  filepath.Join("images", "raw"),
+		".nef":  filepath.Join("images", "raw"),
+		".gif":  filepath.Join("images", "web"),
+		".pdf":  "documents",
+		".docx": filepath.Join("documents", "office"),
+		".xlsx": filepath.Join("documents", "office"),
+	}
+
+	dir := t.TempDir()
+	for _, tt := range configFormats {
+		t.Run(tt.format, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			config, err := LoadConfig(path, "")
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if !reflect.DeepEqual(config.CategoryOrder, wantOrder) {
+				t.Errorf("CategoryOrder = %v, want %v", config.CategoryOrder, wantOrder)
+			}
+			if config.PathTemplate != "{category}/{ext}" {
+				t.Errorf("PathTemplate = %q", config.PathTemplate)
+			}
+			if !reflect.DeepEqual(config.Ignore, []string{"*.tmp", "!keep.tmp"}) {
+				t.Errorf("Ignore = %v", config.Ignore)
+			}
+			if config.Rename == nil || !config.Rename.LowercaseExt {
+				t.Errorf("Rename = %+v, want lowercase_ext", config.Rename)
+			}
+
+			mapping, err := buildExtensionMapping(config)
+			if err != nil {
+				t.Fatalf("buildExtensionMapping: %v", err)
+			}
+			for ext, want := range wantExtensions {
+				if got := mapping.ExtToPath[ext]; got != want {
+					t.Errorf("ExtToPath[%s] = %q, want %q", ext, got, want)
+				}
+			}
+			// .png is claimed by images/web first, then by screenshots
+			wantOverrides := []ExtensionOverride{{Extension: ".png", PreviousPath: filepath.Join("images", "web"), Path: "screenshots"}}
+			if !reflect.DeepEqual(mapping.Overrides, wantOverrides) {
+				t.Errorf("Overrides = %+v, want %+v", mapping.Overrides, wantOverrides)
+			}
+			if len(mapping.Patterns) != 1 || mapping.Patterns[0].Pattern != "Screenshot*" || mapping.Patterns[0].Path != "screenshots" {
+				t.Errorf("Patterns = %+v, want Screenshot* in screenshots", mapping.Patterns)
+			}
+		})
+	}
+}
+
+func TestParseConfigSubcategoryOrder(t *testing.T) {
+	for _, tt := range configFormats {
+		t.Run(tt.format, func(t *testing.T) {
+			config, err := ParseConfig([]byte(tt.data), tt.format)
+			if err != nil {
+				t.Fatalf("ParseConfig: %v", err)
+			}
+			images, err := parseCategory(config.Categories["images"])
+			if err != nil {
+				t.Fatalf("parseCategory: %v", err)
+			}
+			if want := []string{"raw", "web"}; !reflect.DeepEqual(images.SubcategoryOrder, want) {
+				t.Errorf("SubcategoryOrder = %v, want %v", images.SubcategoryOrder, want)
+			}
+		})
+	}
+}
+
+func TestParseConfigOverrideFollowsDeclarationOrder(t *testing.T) {
+	// The same keys in the same order in every format, so the last declaration of .png wins
+	configs := map[string]string{
+		ConfigFormatJSON: `{"categories": {"images": [".png"], "screenshots": [".png"]}}`,
+		ConfigFormatYAML: "categories:\n  images: [\".png\"]\n  screenshots: [\".png\"]\n",
+		ConfigFormatTOML: "[categories]\nimages = [\".png\"]\nscreenshots = [\".png\"]\n",
+	}
+
+	for format, data := range configs {
+		t.Run(format, func(t *testing.T) {
+			config, err := ParseConfig([]byte(data), format)
+			if err != nil {
+				t.Fatalf("ParseConfig: %v", err)
+			}
+			mapping, err := buildExtensionMapping(config)
+			if err != nil {
+				t.Fatalf("buildExtensionMapping: %v", err)
+			}
+			if got := mapping.ExtToPath[".png"]; got != "screenshots" {
+				t.Errorf("ExtToPath[.png] = %q, want screenshots", got)
+			}
+			want := []ExtensionOverride{{Extension: ".png", PreviousPath: "images", Path: "screenshots"}}
+			if !reflect.DeepEqual(mapping.Overrides, want) {
+				t.Errorf("Overrides = %+v, want %+v", mapping.Overrides, want)
+			}
+		})
+	}
+}
+
+func TestDetectConfigFormat(t *testing.T) {
+	tests := []struct {
+		path, format string
+		want         string
+		wantErr      bool
+	}{
+		{path: "config.json", want: ConfigFormatJSON},
+		{path: "config.yaml", want: ConfigFormatYAML},
+		{path: "config.YML", want: ConfigFormatYAML},
+		{path: "config.toml", want: ConfigFormatTOML},
+		{path: "config", want: ConfigFormatJSON},
+		{path: "config.json", format: "yml", want: ConfigFormatYAML},
+		{path: "config.yaml", format: "ini", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := detectConfigFormat(tt.path, tt.format)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("detectConfigFormat(%q, %q) error = %v, wantErr %v", tt.path, tt.format, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("detectConfigFormat(%q, %q) = %q, want %q", tt.path, tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestParseConfigInvalid(t *testing.T) {
+	tests := []struct {
+		format, data string
+	}{
+		{ConfigFormatJSON, `{"categories": `},
+		{ConfigFormatYAML, "categories:\n  images: [\".png\"\n"},
+		{ConfigFormatTOML, "[categories\nimages = 1"},
+	}
+
+	for _, tt := range tests {
+		if _, err := ParseConfig([]byte(tt.data), tt.format); err == nil {
+			t.Errorf("ParseConfig(%s) of invalid data returned no error", tt.format)
+		}
+	}
+}
+
+func TestExtensionMappingMatch(t *testing.T) {
+	config, err := ParseConfig([]byte(`{"categories": {
+  "archives": [".gz", ".tar.gz", ".zip"],
+  "documents": [".pdf"],
+  "images": [".jpg", "glob:IMG_*"],
+  "camera": ["glob:IMG_*.jpg"],
+  "invoices": ["regex:^inv-\\d+\\.pdf$"],
+  "screenshots": ["glob:Screenshot*"],
+  "screen": ["regex:^Screen"],
+  "recordings": ["regex:\\.mov$"],
+  "clips": ["glob:*.mov"]
+}}`), ConfigFormatJSON)
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	mapping, err := buildExtensionMapping(config)
+	if err != nil {
+		t.Fatalf("buildExtensionMapping: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		filename string
+		wantPath string
+		wantExt  string
+		wantOK   bool
+	}{
+		{"extension", "photo.jpg", "images", ".jpg", true},
+		{"extension ignores case", "PHOTO.JPG", "images", ".jpg", true},
+		{"longest extension wins", "backup.tar.gz", "archives", ".tar.gz", true},
+		{"shorter extension alone", "backup.gz", "archives", ".gz", true},
+		{"last extension only", "backup.tar.zip", "archives", ".zip", true},
+		{"unmapped extension", "notes.txt", "", "", false},
+		{"no extension", "README", "", "", false},
+
+		{"glob over extension", "IMG_0001.png", "images", ".png", true},
+		{"glob ignores case", "img_0001.png", "images", ".png", true},
+		{"later glob over earlier glob", "IMG_0001.jpg", "camera", ".jpg", true},
+		{"regex over extension", "inv-42.pdf", "invoices", ".pdf", true},
+		{"regex is case sensitive", "INV-42.pdf", "documents", ".pdf", true},
+		{"regex matches the whole name", "inv-42.pdf.bak", "", "", false},
+		{"later regex over earlier glob", "Screenshot 1.png", "screen", ".png", true},
+		{"earlier glob when the regex misses", "screenshot 1.png", "screenshots", ".png", true},
+		{"later glob over earlier regex", "trip.mov", "clips", ".mov", true},
+		{"pattern without extension", "Screenshot", "screen", "", true},
+		{"pattern keeps the mapped extension", "IMG_0001.tar.gz", "images", ".tar.gz", true},
+	}
+
+	for _, tt := range tests {
+		path, ext, ok := mapping.Match(tt.filename)
+		if path != tt.wantPath || ext != tt.wantExt || ok != tt.wantOK {
+			t.Errorf("%s: Match(%q) = %q, %q, %v, want %q, %q, %v", tt.name, tt.filename, path, ext, ok, tt.wantPath, tt.wantExt, tt.wantOK)
+		}
+	}
+}
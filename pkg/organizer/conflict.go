@@ -0,0 +1,216 @@
+package organizer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// targetReservations tracks the target paths claimed by workers, so concurrent jobs
+// (and planned moves in dry-run mode, where nothing is written) never resolve to the same file
+type targetReservations struct {
+	mu sync.Mutex
+	fs FileSystem
+	// warn reports files that could not be compared
+	warn func(warning string)
+	// paths maps each claimed target path to the source file claiming it
+	paths map[string]string
+}
+
+func newTargetReservations(fsys FileSystem, warn func(string)) *targetReservations {
+	return &targetReservations{fs: fsys, warn: warn, paths: make(map[string]string)}
+}
+
+// resolve decides what happens to a job's file and claims the target path. When the target is
+// already taken the conflict strategy decides between renaming (name_1.ext, name_2.ext, ...),
+// overwriting or deduplicating. Only files that existed before the run are overwritten, a target
+// claimed by another file of the run is renamed around instead. A target that already holds the
+// result of placing the file with mode, such as a link to it left by an earlier run, is not a
+// conflict. When the file should be left where it is, the skip reason is returned
+func (r *targetReservations) resolve(job fileJob, strategy, mode string) (FileMove, string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	move := FileMove{
+		Source:   job.sourcePath,
+		Target:   filepath.Join(job.targetDir, job.filename),
+		Action:   MoveActionMove,
+		Category: job.category,
+	}
+
+	// Only resolve a conflict if the target file actually exists and is not the source itself
+	if !r.taken(move.Target) || move.Target == job.sourcePath {
+		r.paths[move.Target] = job.sourcePath
+		return move, ""
+	}
+
+	_, claimed := r.paths[move.Target]
+	if !claimed && r.placed(job.sourcePath, move.Target, mode) {
+		r.paths[move.Target] = job.sourcePath
+		return move, SkipOrganized
+	}
+
+	switch {
+	case strategy == ConflictSkip:
+		return move, SkipConflict
+	case strategy == ConflictOverwrite && !claimed:
+		move.Action = MoveActionOverwrite
+		r.paths[move.Target] = job.sourcePath
+		return move, ""
+	case (strategy == ConflictOverwriteIfNewer || strategy == ConflictOverwriteIfLarger) && !claimed:
+		if !r.replaces(job.sourcePath, move.Target, strategy) {
+			return move, SkipConflict
+		}
+		move.Action = MoveActionOverwrite
+		r.paths[move.Target] = job.sourcePath
+		return move, ""
+	}
+
+	// Rename (and dedupe when no identical copy exists, or overwrite when another file of the run
+	// claimed the target): append a number to the filename
+	ext := filepath.Ext(job.filename)
+	baseName := strings.TrimSuffix(job.filename, ext)
+	targetPath := move.Target
+	for counter := 1; ; counter++ {
+		if strategy == ConflictDedupe && r.identical(job.sourcePath, targetPath) {
+			move.Target = targetPath
+			move.Action = MoveActionDedupe
+			return move, ""
+		}
+
+		targetPath = filepath.Join(job.targetDir, fmt.Sprintf("%s_%d%s", baseName, counter, ext))
+		if !r.taken(targetPath) {
+			break
+		}
+	}
+
+	move.Target = targetPath
+	r.paths[move.Target] = job.sourcePath
+	return move, ""
+}
+
+// placed reports whether target already holds what placing source with mode would create: a
+// symbolic link to it, a hard link to it, or an identical copy. Moves never leave the source behind
+func (r *targetReservations) placed(source, target, mode string) bool {
+	if mode == "" || mode == ModeMove {
+		return false
+	}
+
+	linkInfo, err := r.fs.Lstat(target)
+	if err != nil || (linkInfo.Mode()&fs.ModeSymlink != 0) != (mode == ModeSymlink) {
+		return false
+	}
+	sourceInfo, err := r.fs.Stat(source)
+	if err != nil {
+		return false
+	}
+	targetInfo, err := r.fs.Stat(target)
+	if err != nil {
+		return false
+	}
+
+	if mode != ModeCopy {
+		return sameFile(sourceInfo, targetInfo)
+	}
+	if sourceInfo.Size() != targetInfo.Size() || sameFile(sourceInfo, targetInfo) {
+		return false
+	}
+	same, err := sameContent(r.fs, source, target)
+	if err != nil {
+		r.warn(fmt.Sprintf("error comparing %s with %s: %v", source, target, err))
+		return false
+	}
+	return same
+}
+
+// taken reports whether a path exists on disk or has already been claimed
+func (r *targetReservations) taken(path string) bool {
+	if _, claimed := r.paths[path]; claimed {
+		return true
+	}
+	_, err := r.fs.Stat(path)
+	return err == nil
+}
+
+// occupant returns the file currently holding a target path. A path claimed by a move that has not
+// happened yet (always the case in dry-run mode) is represented by the source file of that move
+func (r *targetReservations) occupant(path string) (fs.FileInfo, string, error) {
+	info, err := r.fs.Stat(path)
+	if err == nil {
+		return info, path, nil
+	}
+	if source, claimed := r.paths[path]; claimed {
+		if info, err := r.fs.Stat(source); err == nil {
+			return info, source, nil
+		}
+	}
+	return nil, "", err
+}
+
+// replaces reports whether the source should overwrite the target under an overwrite-if strategy
+func (r *targetReservations) replaces(source, target, strategy string) bool {
+	sourceInfo, err := r.fs.Stat(source)
+	if err != nil {
+		return false
+	}
+	targetInfo, _, err := r.occupant(target)
+	if err != nil {
+		return false
+	}
+
+	if strategy == ConflictOverwriteIfLarger {
+		return sourceInfo.Size() > targetInfo.Size()
+	}
+	return sourceInfo.ModTime().After(targetInfo.ModTime())
+}
+
+// identical reports whether the file holding target has the same content as source
+func (r *targetReservations) identical(source, target string) bool {
+	sourceInfo, err := r.fs.Stat(source)
+	if err != nil {
+		return false
+	}
+	targetInfo, occupantPath, err := r.occupant(target)
+	if err != nil || occupantPath == source || sourceInfo.Size() != targetInfo.Size() {
+		return false
+	}
+
+	same, err := sameContent(r.fs, source, occupantPath)
+	if err != nil {
+		r.warn(fmt.Sprintf("error comparing %s with %s: %v", source, occupantPath, err))
+		return false
+	}
+	return same
+}
+
+// sameContent compares two files by their SHA-256 hash
+func sameContent(fsys FileSystem, a, b string) (bool, error) {
+	hashA, err := hashFile(fsys, a)
+	if err != nil {
+		return false, err
+	}
+	hashB, err := hashFile(fsys, b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(hashA, hashB), nil
+}
+
+func hashFile(fsys FileSystem, path string) ([]byte, error) {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
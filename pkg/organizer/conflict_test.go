@@ -0,0 +1,205 @@
+package organizer
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// memContents maps every regular file of a MemFS to its content
+func memContents(t *testing.T, memFS *MemFS) map[string]string {
+	t.Helper()
+	contents := make(map[string]string)
+	for _, path := range memFiles(t, memFS) {
+		data, err := memFS.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[path] = string(data)
+	}
+	return contents
+}
+
+// moveList describes the moves of a run as sorted "source -> target (action)" lines
+func moveList(moves []FileMove) []string {
+	list := make([]string, 0, len(moves))
+	for _, move := range moves {
+		list = append(list, move.Source+" -> "+move.Target+" ("+move.Action+")")
+	}
+	sort.Strings(list)
+	return list
+}
+
+func TestExecuteMemFSConflicts(t *testing.T) {
+	org := newTestOrganizer(t, `{"categories": {"images": [".jpg"]}}`)
+	// diff.jpg differs from the file already organized under its name, with the same size, so
+	// only its hash tells them apart. same.jpg is an identical copy of the organized one
+	before := map[string]string{
+		"/downloads/diff.jpg":            "new",
+		"/downloads/same.jpg":            "same",
+		"/downloads/fresh.jpg":           "fresh",
+		"/downloads/images/jpg/diff.jpg": "old",
+		"/downloads/images/jpg/same.jpg": "same",
+	}
+
+	tests := []struct {
+		strategy string
+		moves    []string
+		after    map[string]string
+		// skipped counts the files left in place by the strategy, organized and deduped count the
+		// files moved and removed
+		skipped, organized, deduped int
+	}{
+		{
+			strategy: ConflictRename,
+			moves: []string{
+				"/downloads/diff.jpg -> /downloads/images/jpg/diff_1.jpg (move)",
+				"/downloads/fresh.jpg -> /downloads/images/jpg/fresh.jpg (move)",
+				"/downloads/same.jpg -> /downloads/images/jpg/same_1.jpg (move)",
+			},
+			after: map[string]string{
+				"/downloads/images/jpg/diff.jpg":   "old",
+				"/downloads/images/jpg/diff_1.jpg": "new",
+				"/downloads/images/jpg/fresh.jpg":  "fresh",
+				"/downloads/images/jpg/same.jpg":   "same",
+				"/downloads/images/jpg/same_1.jpg": "same",
+			},
+			organized: 3,
+		},
+		{
+			strategy: ConflictSkip,
+			moves:    []string{"/downloads/fresh.jpg -> /downloads/images/jpg/fresh.jpg (move)"},
+			after: map[string]string{
+				"/downloads/diff.jpg":             "new",
+				"/downloads/same.jpg":             "same",
+				"/downloads/images/jpg/diff.jpg":  "old",
+				"/downloads/images/jpg/fresh.jpg": "fresh",
+				"/downloads/images/jpg/same.jpg":  "same",
+			},
+			skipped:   2,
+			organized: 1,
+		},
+		{
+			strategy: ConflictOverwrite,
+			moves: []string{
+				"/downloads/diff.jpg -> /downloads/images/jpg/diff.jpg (overwrite)",
+				"/downloads/fresh.jpg -> /downloads/images/jpg/fresh.jpg (move)",
+				"/downloads/same.jpg -> /downloads/images/jpg/same.jpg (overwrite)",
+			},
+			after: map[string]string{
+				"/downloads/images/jpg/diff.jpg":  "new",
+				"/downloads/images/jpg/fresh.jpg": "fresh",
+				"/downloads/images/jpg/same.jpg":  "same",
+			},
+			organized: 3,
+		},
+		{
+			strategy: ConflictDedupe,
+			moves: []string{
+				"/downloads/diff.jpg -> /downloads/images/jpg/diff_1.jpg (move)",
+				"/downloads/fresh.jpg -> /downloads/images/jpg/fresh.jpg (move)",
+				"/downloads/same.jpg -> /downloads/images/jpg/same.jpg (dedupe)",
+			},
+			after: map[string]string{
+				"/downloads/images/jpg/diff.jpg":   "old",
+				"/downloads/images/jpg/diff_1.jpg": "new",
+				"/downloads/images/jpg/fresh.jpg":  "fresh",
+				"/downloads/images/jpg/same.jpg":   "same",
+			},
+			organized: 2,
+			deduped:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			memFS := newTestMemFS(t, before)
+			opts := Options{SourcePath: "/downloads", NumWorkers: 2, OnConflict: tt.strategy, FS: memFS, RecordFiles: true}
+
+			// A dry run plans the same moves without touching anything
+			plan, err := org.Plan(context.Background(), opts)
+			if err != nil {
+				t.Fatalf("Plan: %v", err)
+			}
+			if got := moveList(plan.Moves); !slices.Equal(got, tt.moves) {
+				t.Errorf("planned moves = %q, want %q", got, tt.moves)
+			}
+			if got := memContents(t, memFS); !maps.Equal(got, before) {
+				t.Errorf("Plan changed the files to %v", got)
+			}
+
+			stats, err := org.Execute(context.Background(), opts)
+			if err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			if got := moveList(stats.Moves); !slices.Equal(got, tt.moves) {
+				t.Errorf("moves = %q, want %q", got, tt.moves)
+			}
+			if got := memContents(t, memFS); !maps.Equal(got, tt.after) {
+				t.Errorf("files after the run = %v, want %v", got, tt.after)
+			}
+			if stats.SkipsByReason[SkipConflict] != tt.skipped || stats.OrganizedFiles != tt.organized || stats.DedupedFiles != tt.deduped {
+				t.Errorf("stats = %d organized, %d deduped, skipped %v, want %d, %d and %d conflicts",
+					stats.OrganizedFiles, stats.DedupedFiles, stats.SkipsByReason, tt.organized, tt.deduped, tt.skipped)
+			}
+
+			// A second run leaves everything as it is: organized files are in place and the
+			// conflicts the strategy skipped are skipped again
+			stats, err = org.Execute(context.Background(), opts)
+			if err != nil {
+				t.Fatalf("second Execute: %v", err)
+			}
+			if len(stats.Moves) != 0 || stats.SkipsByReason[SkipConflict] != tt.skipped {
+				t.Errorf("second run moved %q and skipped %v, want nothing moved and %d conflicts", moveList(stats.Moves), stats.SkipsByReason, tt.skipped)
+			}
+			if got := memContents(t, memFS); !maps.Equal(got, tt.after) {
+				t.Errorf("files after the second run = %v, want %v", got, tt.after)
+			}
+		})
+	}
+}
+
+func TestExecuteMemFSConflictsWithinRun(t *testing.T) {
+	org := newTestOrganizer(t, `{"categories": {"images": [".jpg"]}}`)
+	before := map[string]string{
+		"/downloads/a/photo.jpg": "first",
+		"/downloads/b/photo.jpg": "second",
+	}
+
+	// Only files that existed before the run are overwritten or deduplicated against, files
+	// of the run claiming the same target are renamed around each other
+	for _, strategy := range []string{ConflictOverwrite, ConflictDedupe, ConflictOverwriteIfLarger} {
+		t.Run(strategy, func(t *testing.T) {
+			memFS := newTestMemFS(t, before)
+			opts := Options{SourcePath: "/downloads", NumWorkers: 2, Recursive: true, OnConflict: strategy, FS: memFS}
+
+			plan, err := org.Plan(context.Background(), opts)
+			if err != nil {
+				t.Fatalf("Plan: %v", err)
+			}
+			var targets []string
+			for _, move := range plan.Moves {
+				if move.Action != MoveActionMove {
+					t.Errorf("planned %s of %s, want a move", move.Action, move.Source)
+				}
+				targets = append(targets, move.Target)
+			}
+			sort.Strings(targets)
+			want := []string{"/downloads/images/jpg/photo.jpg", "/downloads/images/jpg/photo_1.jpg"}
+			if !slices.Equal(targets, want) {
+				t.Errorf("planned targets = %v, want %v", targets, want)
+			}
+
+			if _, err := org.Execute(context.Background(), opts); err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			after := memContents(t, memFS)
+			contents := slices.Sorted(maps.Values(after))
+			if !slices.Equal(slices.Sorted(maps.Keys(after)), want) || !slices.Equal(contents, []string{"first", "second"}) {
+				t.Errorf("files after the run = %v, want both files kept", after)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package organizer
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// mimePrefix marks a content type entry in a category's extension list
+const mimePrefix = "mime:"
+
+// sniffLen is how many leading bytes are read to detect the content type of a file
+const sniffLen = 512
+
+// genericContentTypes are detected types that say nothing useful about a file's content
+var genericContentTypes = map[string]bool{
+	"application/octet-stream": true,
+	"text/plain":               true,
+	"text/xml":                 true,
+}
+
+// mediaMajorTypes are the top-level types of binary media formats
+var mediaMajorTypes = map[string]bool{
+	"audio": true,
+	"font":  true,
+	"image": true,
+	"video": true,
+}
+
+// sniffedTypeAliases maps types reported by http.DetectContentType to their registered name
+var sniffedTypeAliases = map[string]string{
+	"application/ogg":    "audio/ogg",
+	"application/x-gzip": "application/gzip",
+}
+
+// addMIMEType maps a content type (or a "type/*" wildcard) to path, recording an override when
+// another category had it
+func addMIMEType(mapping *ExtensionMapping, path, mediaType string) error {
+	mediaType, err := normalizeMIMEType(mediaType)
+	if err != nil {
+		return fmt.Errorf("invalid content type in %s: %w", path, err)
+	}
+
+	if previous, exists := mapping.MIMEToPath[mediaType]; exists && previous != path {
+		mapping.Overrides = append(mapping.Overrides, ExtensionOverride{
+			Extension:    mimePrefix + mediaType,
+			PreviousPath: previous,
+			Path:         path,
+		})
+	}
+	mapping.MIMEToPath[mediaType] = path
+	return nil
+}
+
+// normalizeMIMEType checks that a content type has the "type/subtype" or "type/*" form and
+// returns it in lowercase
+func normalizeMIMEType(mediaType string) (string, error) {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	major, minor, found := strings.Cut(mediaType, "/")
+	if !found || major == "" || minor == "" || major == "*" || strings.ContainsAny(mediaType, " ;") {
+		return "", fmt.Errorf("%q is not of the form type/subtype or type/*", mediaType)
+	}
+	return mediaType, nil
+}
+
+// MatchContent returns the directory a file with the given content type is organized into, along
+// with the extension naming its extension folder. Content types mapped in the config take
+// precedence, exact types before wildcards, then the mapped extensions registered for the type
+func (m *ExtensionMapping) MatchContent(mediaType string) (path, ext string, ok bool) {
+	// Prefer an extension of the type the config knows about
+	extensions, _ := mime.ExtensionsByType(mediaType)
+	for _, candidate := range extensions {
+		if mapped, exists := m.ExtToPath[candidate]; exists {
+			path, ext, ok = mapped, candidate, true
+			break
+		}
+	}
+
+	if mapped, exists := m.MIMEToPath[mediaType]; exists {
+		path, ok = mapped, true
+	} else if mapped, exists := m.MIMEToPath[majorType(mediaType)+"/*"]; exists {
+		path, ok = mapped, true
+	}
+
+	// Without a mapped extension the folder is named after the subtype (image/png becomes png)
+	if ok && ext == "" {
+		_, subtype, _ := strings.Cut(mediaType, "/")
+		ext = "." + strings.TrimPrefix(subtype, "x-")
+	}
+	return path, ext, ok
+}
+
+// classifyContent classifies a file by its content when its name did not match, or when its
+// content contradicts its extension, such as a PDF saved as .jpg. Files matched by a name
+// pattern, and files whose extension has no known content type, are left as they are
+func (r *organizeRun) classifyContent(path, name, folder, ext string, matched bool) (string, string, bool) {
+	var expected string
+	if matched {
+		if _, byPattern := r.mapping.matchPattern(name, strings.ToLower(name)); byPattern {
+			return folder, ext, matched
+		}
+		if expected = expectedContentType(ext); expected == "" {
+			return folder, ext, matched
+		}
+	}
+
+	mediaType, err := detectContentType(r.opts.FS, path)
+	if err != nil || mediaType == "" {
+		return folder, ext, matched
+	}
+	if matched && !contentContradicts(expected, mediaType) {
+		return folder, ext, matched
+	}
+
+	if contentFolder, contentExt, ok := r.mapping.MatchContent(mediaType); ok {
+		return contentFolder, contentExt, true
+	}
+	return folder, ext, matched
+}
+
+// detectContentType returns the content type of a file from its leading bytes, without
+// parameters. Types that say nothing useful about the content are returned empty
+func detectContentType(fsys FileSystem, path string) (string, error) {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if n == 0 {
+		return "", nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(buf[:n]))
+	if err != nil || genericContentTypes[mediaType] {
+		return "", nil
+	}
+	if alias, exists := sniffedTypeAliases[mediaType]; exists {
+		mediaType = alias
+	}
+	return mediaType, nil
+}
+
+// expectedContentType returns the content type registered for an extension, without parameters
+func expectedContentType(ext string) string {
+	if ext == "" {
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(mime.TypeByExtension(ext))
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+// contentContradicts reports whether detected content is clearly not what an extension's expected
+// content type says. Audio and video share container formats, so they never contradict each other,
+// and text is only trusted to contradict media extensions (an HTML error page saved as .jpg)
+func contentContradicts(expected, detected string) bool {
+	if contentFamily(expected) == contentFamily(detected) {
+		return false
+	}
+	return majorType(detected) != "text" || mediaMajorTypes[majorType(expected)]
+}
+
+// contentFamily groups content types whose formats overlap, video shares its containers with audio
+func contentFamily(mediaType string) string {
+	if major := majorType(mediaType); major != "video" {
+		return major
+	}
+	return "audio"
+}
+
+// majorType returns the top-level type of a content type (image for image/png)
+func majorType(mediaType string) string {
+	major, _, _ := strings.Cut(mediaType, "/")
+	return major
+}
@@ -0,0 +1,390 @@
+package organizer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// exifDateLayout is the format of EXIF date and time values
+const exifDateLayout = "2006:01:02 15:04:05"
+
+// TIFF and EXIF tags holding dates
+const (
+	tiffTagDateTime         = 0x0132
+	tiffTagExifIFD          = 0x8769
+	exifTagDateTimeOriginal = 0x9003
+)
+
+// Limits guarding against corrupt files
+const (
+	// maxExifScan is how much of a file is read when it cannot be read at random offsets
+	maxExifScan = 4 << 20
+	// maxIFDEntries is the largest IFD that is read
+	maxIFDEntries = 1000
+	// maxBoxScan is how many sibling boxes of a HEIF container are inspected when looking for one
+	maxBoxScan = 64
+	// maxHEIFMetaBox is the largest HEIF item location or information box that is read
+	maxHEIFMetaBox = 1 << 20
+)
+
+// exifDateTaken returns the EXIF DateTimeOriginal of a JPEG, TIFF or HEIC file, falling back to
+// the DateTime of the image. It returns false for other files and files without an EXIF date
+func exifDateTaken(fsys FileSystem, path string) (time.Time, bool) {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer file.Close()
+
+	r, ok := file.(io.ReaderAt)
+	if !ok {
+		data, err := io.ReadAll(io.LimitReader(file, maxExifScan))
+		if err != nil {
+			return time.Time{}, false
+		}
+		r = bytes.NewReader(data)
+	}
+
+	var header [12]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return time.Time{}, false
+	}
+
+	var tiff *io.SectionReader
+	switch {
+	case header[0] == 0xFF && header[1] == 0xD8:
+		tiff = jpegExif(r)
+	case string(header[:4]) == "II*\x00" || string(header[:4]) == "MM\x00*":
+		tiff = io.NewSectionReader(r, 0, 1<<62)
+	case string(header[4:8]) == "ftyp":
+		tiff = heifExif(r)
+	}
+	if tiff == nil {
+		return time.Time{}, false
+	}
+	return tiffDate(tiff)
+}
+
+// jpegExif returns the TIFF structure embedded in the APP1 segment of a JPEG file
+func jpegExif(r io.ReaderAt) *io.SectionReader {
+	offset := int64(2)
+	for {
+		var marker [4]byte
+		if _, err := r.ReadAt(marker[:], offset); err != nil || marker[0] != 0xFF {
+			return nil
+		}
+
+		switch kind := marker[1]; {
+		case kind == 0xFF:
+			// Fill byte before a marker
+			offset++
+			continue
+		case kind == 0x01 || (kind >= 0xD0 && kind <= 0xD8):
+			// Markers without a segment
+			offset += 2
+			continue
+		case kind == 0xDA || kind == 0xD9:
+			// The image data starts, EXIF always comes before it
+			return nil
+		}
+
+		length := int64(binary.BigEndian.Uint16(marker[2:]))
+		if marker[1] == 0xE1 && length >= 8 {
+			var id [6]byte
+			if _, err := r.ReadAt(id[:], offset+4); err == nil && string(id[:]) == "Exif\x00\x00" {
+				return io.NewSectionReader(r, offset+10, length-8)
+			}
+		}
+		offset += 2 + length
+	}
+}
+
+// ifdEntry is an entry of a TIFF image file directory
+type ifdEntry struct {
+	kind  uint16
+	count uint32
+	value [4]byte
+}
+
+// tiffDate reads DateTimeOriginal from the EXIF directory of a TIFF structure, or DateTime from
+// its first directory
+func tiffDate(r *io.SectionReader) (time.Time, bool) {
+	var header [8]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return time.Time{}, false
+	}
+
+	var order binary.ByteOrder
+	switch string(header[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return time.Time{}, false
+	}
+	if order.Uint16(header[2:]) != 42 {
+		return time.Time{}, false
+	}
+
+	ifd0 := readIFD(r, order, int64(order.Uint32(header[4:])))
+	if pointer, exists := ifd0[tiffTagExifIFD]; exists {
+		exif := readIFD(r, order, int64(order.Uint32(pointer.value[:])))
+		if taken, ok := ifdDate(r, order, exif[exifTagDateTimeOriginal]); ok {
+			return taken, true
+		}
+	}
+	return ifdDate(r, order, ifd0[tiffTagDateTime])
+}
+
+// readIFD reads the entries of the image file directory at offset, keyed by tag
+func readIFD(r io.ReaderAt, order binary.ByteOrder, offset int64) map[uint16]ifdEntry {
+	var countBytes [2]byte
+	if _, err := r.ReadAt(countBytes[:], offset); err != nil {
+		return nil
+	}
+	count := int(order.Uint16(countBytes[:]))
+	if count > maxIFDEntries {
+		return nil
+	}
+
+	data := make([]byte, count*12)
+	if _, err := r.ReadAt(data, offset+2); err != nil {
+		return nil
+	}
+
+	entries := make(map[uint16]ifdEntry, count)
+	for i := 0; i < count; i++ {
+		raw := data[i*12 : i*12+12]
+		entry := ifdEntry{kind: order.Uint16(raw[2:]), count: order.Uint32(raw[4:])}
+		copy(entry.value[:], raw[8:])
+		entries[order.Uint16(raw)] = entry
+	}
+	return entries
+}
+
+// ifdDate parses an ASCII date entry
+func ifdDate(r io.ReaderAt, order binary.ByteOrder, entry ifdEntry) (time.Time, bool) {
+	const asciiType = 2
+	if entry.kind != asciiType || entry.count < uint32(len(exifDateLayout)) {
+		return time.Time{}, false
+	}
+
+	value := make([]byte, len(exifDateLayout))
+	if _, err := r.ReadAt(value, int64(order.Uint32(entry.value[:]))); err != nil {
+		return time.Time{}, false
+	}
+
+	taken, err := time.ParseInLocation(exifDateLayout, string(value), time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return taken, true
+}
+
+// heifExif returns the TIFF structure of the Exif item of a HEIF (HEIC) file, located through the
+// item information and item location boxes of its meta box
+func heifExif(r io.ReaderAt) *io.SectionReader {
+	metaStart, metaEnd, ok := findBox(r, 0, 1<<62, "meta")
+	if !ok {
+		return nil
+	}
+	// meta is a full box, its children follow the version and flags
+	metaStart += 4
+
+	iinf, ok := readBox(r, metaStart, metaEnd, "iinf")
+	if !ok {
+		return nil
+	}
+	itemID, ok := heifExifItemID(iinf)
+	if !ok {
+		return nil
+	}
+
+	iloc, ok := readBox(r, metaStart, metaEnd, "iloc")
+	if !ok {
+		return nil
+	}
+	offset, length, ok := heifItemLocation(iloc, itemID)
+	if !ok || length < 4 {
+		return nil
+	}
+
+	// The item starts with the offset of the TIFF header, past the "Exif\0\0" prefix
+	var headerOffset [4]byte
+	if _, err := r.ReadAt(headerOffset[:], offset); err != nil {
+		return nil
+	}
+	skip := 4 + int64(binary.BigEndian.Uint32(headerOffset[:]))
+	if skip >= length {
+		return nil
+	}
+	return io.NewSectionReader(r, offset+skip, length-skip)
+}
+
+// findBox returns the content range of the first box of the given type between start and end
+func findBox(r io.ReaderAt, start, end int64, boxType string) (int64, int64, bool) {
+	offset := start
+	for i := 0; i < maxBoxScan && offset+8 <= end; i++ {
+		var header [16]byte
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return 0, 0, false
+		}
+
+		size, headerLen := int64(binary.BigEndian.Uint32(header[:4])), int64(8)
+		switch size {
+		case 0:
+			// The box extends to the end of its parent
+			size = end - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:], offset+8); err != nil {
+				return 0, 0, false
+			}
+			size, headerLen = int64(binary.BigEndian.Uint64(header[8:])), 16
+		}
+		if size < headerLen {
+			return 0, 0, false
+		}
+
+		if string(header[4:8]) == boxType {
+			return offset + headerLen, offset + size, true
+		}
+		offset += size
+	}
+	return 0, 0, false
+}
+
+// readBox reads the content of the first box of the given type between start and end
+func readBox(r io.ReaderAt, start, end int64, boxType string) ([]byte, bool) {
+	contentStart, contentEnd, ok := findBox(r, start, end, boxType)
+	if !ok || contentEnd-contentStart > maxHEIFMetaBox {
+		return nil, false
+	}
+	data := make([]byte, contentEnd-contentStart)
+	if _, err := r.ReadAt(data, contentStart); err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// heifExifItemID returns the ID of the Exif item listed in an item information box
+func heifExifItemID(iinf []byte) (uint32, bool) {
+	c := &boxCursor{data: iinf}
+	version := c.uint(1)
+	c.uint(3) // flags
+	if version == 0 {
+		c.uint(2) // entry count
+	} else {
+		c.uint(4)
+	}
+
+	// The item information entries follow, as infe boxes
+	for pos := c.pos; !c.failed && pos+8 <= len(iinf); {
+		size := int(binary.BigEndian.Uint32(iinf[pos:]))
+		if size < 8 || pos+size > len(iinf) {
+			return 0, false
+		}
+
+		if string(iinf[pos+4:pos+8]) == "infe" {
+			infe := &boxCursor{data: iinf[pos+8 : pos+size]}
+			infeVersion := infe.uint(1)
+			infe.uint(3) // flags
+			var itemID uint64
+			switch infeVersion {
+			case 2:
+				itemID = infe.uint(2)
+			case 3:
+				itemID = infe.uint(4)
+			}
+			infe.uint(2) // protection index
+			if itemType := infe.bytes(4); !infe.failed && infeVersion >= 2 && string(itemType) == "Exif" {
+				return uint32(itemID), true
+			}
+		}
+		pos += size
+	}
+	return 0, false
+}
+
+// heifItemLocation returns the file offset and length of the first extent of an item listed in
+// an item location box. Only items stored in the file itself are supported
+func heifItemLocation(iloc []byte, itemID uint32) (int64, int64, bool) {
+	c := &boxCursor{data: iloc}
+	version := c.uint(1)
+	c.uint(3) // flags
+	sizes := c.uint(2)
+	offsetSize, lengthSize := int(sizes>>12&0xF), int(sizes>>8&0xF)
+	baseOffsetSize, indexSize := int(sizes>>4&0xF), int(sizes&0xF)
+	if version == 0 {
+		indexSize = 0
+	}
+
+	var itemCount uint64
+	if version < 2 {
+		itemCount = c.uint(2)
+	} else {
+		itemCount = c.uint(4)
+	}
+
+	for i := uint64(0); i < itemCount && !c.failed; i++ {
+		var id uint64
+		if version < 2 {
+			id = c.uint(2)
+		} else {
+			id = c.uint(4)
+		}
+		constructionMethod := uint64(0)
+		if version == 1 || version == 2 {
+			constructionMethod = c.uint(2) & 0xF
+		}
+		c.uint(2) // data reference index
+		baseOffset := c.uint(baseOffsetSize)
+		extentCount := c.uint(2)
+
+		var firstOffset, firstLength uint64
+		for e := uint64(0); e < extentCount && !c.failed; e++ {
+			c.uint(indexSize)
+			extentOffset, extentLength := c.uint(offsetSize), c.uint(lengthSize)
+			if e == 0 {
+				firstOffset, firstLength = extentOffset, extentLength
+			}
+		}
+
+		if uint32(id) == itemID {
+			if c.failed || constructionMethod != 0 || extentCount == 0 {
+				return 0, 0, false
+			}
+			return int64(baseOffset + firstOffset), int64(firstLength), true
+		}
+	}
+	return 0, 0, false
+}
+
+// boxCursor reads big-endian fields from the content of a box, remembering when it ran out of data
+type boxCursor struct {
+	data   []byte
+	pos    int
+	failed bool
+}
+
+// uint reads an unsigned integer of size bytes (0 to 8)
+func (c *boxCursor) uint(size int) uint64 {
+	field := c.bytes(size)
+	var value uint64
+	for _, b := range field {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
+
+func (c *boxCursor) bytes(size int) []byte {
+	if c.failed || size < 0 || c.pos+size > len(c.data) {
+		c.failed = true
+		return nil
+	}
+	field := c.data[c.pos : c.pos+size]
+	c.pos += size
+	return field
+}
@@ -0,0 +1,390 @@
+package organizer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+)
+
+const (
+	testDateTime = "2021:03:04 05:06:07"
+	testOriginal = "2020:01:02 03:04:05"
+)
+
+// tiffFixture builds a TIFF structure whose first directory holds dateTime and whose EXIF
+// directory holds original. Empty dates leave their entry out, the strings come last
+func tiffFixture(order binary.ByteOrder, dateTime, original string) []byte {
+	var ifd0Count int
+	if dateTime != "" {
+		ifd0Count++
+	}
+	if original != "" {
+		ifd0Count++
+	}
+	ifd0Len := 2 + 12*ifd0Count + 4
+	exifOffset := 8 + ifd0Len
+	stringsOffset := exifOffset
+	if original != "" {
+		stringsOffset += 2 + 12 + 4
+	}
+
+	buf := &bytes.Buffer{}
+	if order == binary.LittleEndian {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+	binary.Write(buf, order, uint16(42))
+	binary.Write(buf, order, uint32(8))
+
+	entry := func(tag, kind uint16, count, value uint32) {
+		binary.Write(buf, order, tag)
+		binary.Write(buf, order, kind)
+		binary.Write(buf, order, count)
+		binary.Write(buf, order, value)
+	}
+	var strs []string
+
+	binary.Write(buf, order, uint16(ifd0Count))
+	if dateTime != "" {
+		entry(tiffTagDateTime, 2, 20, uint32(stringsOffset+20*len(strs)))
+		strs = append(strs, dateTime)
+	}
+	if original != "" {
+		entry(tiffTagExifIFD, 4, 1, uint32(exifOffset))
+	}
+	binary.Write(buf, order, uint32(0))
+
+	if original != "" {
+		binary.Write(buf, order, uint16(1))
+		entry(exifTagDateTimeOriginal, 2, 20, uint32(stringsOffset+20*len(strs)))
+		strs = append(strs, original)
+		binary.Write(buf, order, uint32(0))
+	}
+
+	for _, s := range strs {
+		buf.WriteString(s)
+		buf.WriteByte(0)
+	}
+	return buf.Bytes()
+}
+
+// jpegSegment builds a JPEG marker segment
+func jpegSegment(marker byte, content []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(2+len(content)))
+	return append(segment, content...)
+}
+
+// jpegFixture builds a JPEG file from segments, followed by the start of the image data
+func jpegFixture(segments ...[]byte) []byte {
+	data := []byte{0xFF, 0xD8}
+	for _, segment := range segments {
+		data = append(data, segment...)
+	}
+	data = append(data, jpegSegment(0xDA, []byte{1, 2, 3})...)
+	return append(data, 0xFF, 0xD9)
+}
+
+// exifSegment builds the APP1 segment holding a TIFF structure
+func exifSegment(tiff []byte) []byte {
+	return jpegSegment(0xE1, append([]byte("Exif\x00\x00"), tiff...))
+}
+
+// box builds an ISO base media box
+func box(boxType string, content ...[]byte) []byte {
+	data := make([]byte, 8)
+	copy(data[4:], boxType)
+	for _, c := range content {
+		data = append(data, c...)
+	}
+	binary.BigEndian.PutUint32(data, uint32(len(data)))
+	return data
+}
+
+// infeBox builds a version 2 item information entry
+func infeBox(itemID uint16, itemType string) []byte {
+	content := []byte{2, 0, 0, 0}
+	content = binary.BigEndian.AppendUint16(content, itemID)
+	content = binary.BigEndian.AppendUint16(content, 0)
+	content = append(content, itemType...)
+	return box("infe", append(content, 0))
+}
+
+// ilocV0 builds a version 0 item location box content with 4 byte offsets and lengths and a
+// single extent per item
+func ilocV0(items ...[3]uint32) []byte {
+	content := []byte{0, 0, 0, 0, 0x44, 0x00}
+	content = binary.BigEndian.AppendUint16(content, uint16(len(items)))
+	for _, item := range items {
+		content = binary.BigEndian.AppendUint16(content, uint16(item[0]))
+		content = binary.BigEndian.AppendUint16(content, 0) // data reference index
+		content = binary.BigEndian.AppendUint16(content, 1) // extent count
+		content = binary.BigEndian.AppendUint32(content, item[1])
+		content = binary.BigEndian.AppendUint32(content, item[2])
+	}
+	return content
+}
+
+// heifFixture builds a HEIC file whose Exif item, stored in the mdat box, holds a TIFF structure
+func heifFixture(tiff []byte) []byte {
+	ftyp := box("ftyp", []byte("heic"), []byte{0, 0, 0, 0})
+	item := append([]byte{0, 0, 0, 6}, "Exif\x00\x00"...)
+	item = append(item, tiff...)
+
+	iinf := append([]byte{0, 0, 0, 0, 0, 2}, infeBox(1, "hvc1")...)
+	iinf = append(iinf, infeBox(2, "Exif")...)
+	meta := func(itemOffset uint32) []byte {
+		iloc := ilocV0([3]uint32{1, 0, 0}, [3]uint32{2, itemOffset, uint32(len(item))})
+		return box("meta", []byte{0, 0, 0, 0}, box("hdlr", make([]byte, 24)), box("iinf", iinf), box("iloc", iloc))
+	}
+
+	// The location does not change the size of the meta box, so it is built twice
+	itemOffset := len(ftyp) + len(meta(0)) + 8
+	data := append(ftyp, meta(uint32(itemOffset))...)
+	return append(data, box("mdat", item)...)
+}
+
+// localTime parses an EXIF date the way exifDateTaken does
+func localTime(value string) time.Time {
+	t, err := time.ParseInLocation(exifDateLayout, value, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestTiffDate(t *testing.T) {
+	valid := tiffFixture(binary.LittleEndian, testDateTime, testOriginal)
+
+	withData := func(data []byte, change func([]byte)) []byte {
+		data = bytes.Clone(data)
+		change(data)
+		return data
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"little endian prefers DateTimeOriginal", valid, testOriginal},
+		{"big endian prefers DateTimeOriginal", tiffFixture(binary.BigEndian, testDateTime, testOriginal), testOriginal},
+		{"DateTime only", tiffFixture(binary.BigEndian, testDateTime, ""), testDateTime},
+		{"DateTimeOriginal only", tiffFixture(binary.LittleEndian, "", testOriginal), testOriginal},
+		{"invalid DateTimeOriginal falls back", tiffFixture(binary.LittleEndian, testDateTime, "0000:00:00 00:00:00"), testDateTime},
+		{"no dates", tiffFixture(binary.LittleEndian, "", ""), ""},
+		{"empty", nil, ""},
+		{"unknown byte order", withData(valid, func(d []byte) { copy(d, "XX") }), ""},
+		{"wrong magic number", withData(valid, func(d []byte) { d[2] = 43 }), ""},
+		{"directory past the end", withData(valid, func(d []byte) { binary.LittleEndian.PutUint32(d[4:], 1<<20) }), ""},
+		{"oversized directory", withData(valid, func(d []byte) { binary.LittleEndian.PutUint16(d[8:], maxIFDEntries+1) }), ""},
+		{"date count too short", withData(tiffFixture(binary.LittleEndian, testDateTime, ""), func(d []byte) { binary.LittleEndian.PutUint32(d[14:], 4) }), ""},
+		{"date past the end", withData(tiffFixture(binary.LittleEndian, testDateTime, ""), func(d []byte) { binary.LittleEndian.PutUint32(d[18:], 1<<20) }), ""},
+		{"non-ASCII date", withData(tiffFixture(binary.LittleEndian, testDateTime, ""), func(d []byte) { binary.LittleEndian.PutUint16(d[12:], 3) }), ""},
+		{"truncated strings", valid[:len(valid)-30], ""},
+		{"truncated directory", valid[:20], ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tiffDate(io.NewSectionReader(bytes.NewReader(tt.data), 0, int64(len(tt.data))))
+			if tt.want == "" {
+				if ok {
+					t.Errorf("tiffDate = %v, want no date", got)
+				}
+				return
+			}
+			if !ok || !got.Equal(localTime(tt.want)) {
+				t.Errorf("tiffDate = %v, %v, want %s", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestJpegExif(t *testing.T) {
+	tiff := tiffFixture(binary.BigEndian, testDateTime, testOriginal)
+	app0 := jpegSegment(0xE0, []byte("JFIF\x00\x01\x02\x00\x00\x01\x00\x01\x00\x00"))
+	xmp := jpegSegment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00<x/>"))
+
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"APP1 first", jpegFixture(exifSegment(tiff)), true},
+		{"after APP0 and XMP", jpegFixture(app0, xmp, exifSegment(tiff)), true},
+		{"fill bytes before a marker", jpegFixture(append([]byte{0xFF, 0xFF}, exifSegment(tiff)...)), true},
+		{"no APP1", jpegFixture(app0), false},
+		{"XMP only", jpegFixture(xmp), false},
+		{"EXIF after the image data", append(jpegFixture(app0), exifSegment(tiff)...), false},
+		{"APP1 too short for the identifier", jpegFixture(jpegSegment(0xE1, []byte("Exi"))), false},
+		{"no marker after a segment", append(append([]byte{0xFF, 0xD8}, app0...), 0x00, 0xE1), false},
+		{"truncated before the APP1 segment", jpegFixture(app0)[:10], false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			section := jpegExif(bytes.NewReader(tt.data))
+			if !tt.want {
+				if section != nil {
+					t.Error("jpegExif found EXIF data, want none")
+				}
+				return
+			}
+			if section == nil {
+				t.Fatal("jpegExif found no EXIF data")
+			}
+			got := make([]byte, section.Size())
+			if _, err := section.ReadAt(got, 0); err != nil || !bytes.Equal(got, tiff) {
+				t.Errorf("jpegExif section = %x, %v, want the TIFF structure", got, err)
+			}
+		})
+	}
+}
+
+func TestHeifExif(t *testing.T) {
+	tiff := tiffFixture(binary.LittleEndian, testDateTime, testOriginal)
+	valid := heifFixture(tiff)
+
+	// Without an Exif item or without an item location box
+	noExif := bytes.Replace(valid, []byte("Exif\x00"), []byte("mime\x00"), 1)
+	noIloc := bytes.Replace(valid, []byte("iloc"), []byte("free"), 1)
+	noMeta := bytes.Replace(valid, []byte("meta"), []byte("free"), 1)
+
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"valid", valid, true},
+		{"no Exif item", noExif, false},
+		{"no item location", noIloc, false},
+		{"no meta box", noMeta, false},
+		{"truncated meta box", valid[:40], false},
+		{"box smaller than its header", append(box("ftyp", []byte("heic")), 0, 0, 0, 4, 'm', 'e', 't', 'a'), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			section := heifExif(bytes.NewReader(tt.data))
+			if !tt.want {
+				if section != nil {
+					t.Error("heifExif found EXIF data, want none")
+				}
+				return
+			}
+			if section == nil {
+				t.Fatal("heifExif found no EXIF data")
+			}
+			got := make([]byte, section.Size())
+			if _, err := section.ReadAt(got, 0); err != nil || !bytes.Equal(got, tiff) {
+				t.Errorf("heifExif section = %x, %v, want the TIFF structure", got, err)
+			}
+		})
+	}
+}
+
+func TestHeifItemLocation(t *testing.T) {
+	// Version 1 with 4 byte offsets, lengths and base offsets
+	ilocV1 := func(constructionMethod uint16) []byte {
+		content := []byte{1, 0, 0, 0, 0x44, 0x40}
+		content = binary.BigEndian.AppendUint16(content, 1)
+		content = binary.BigEndian.AppendUint16(content, 7) // item ID
+		content = binary.BigEndian.AppendUint16(content, constructionMethod)
+		content = binary.BigEndian.AppendUint16(content, 0)    // data reference index
+		content = binary.BigEndian.AppendUint32(content, 1000) // base offset
+		content = binary.BigEndian.AppendUint16(content, 2)    // extent count
+		content = binary.BigEndian.AppendUint32(content, 50)
+		content = binary.BigEndian.AppendUint32(content, 60)
+		content = binary.BigEndian.AppendUint32(content, 200)
+		return binary.BigEndian.AppendUint32(content, 10)
+	}
+	// Version 2 with 8 byte offsets and 4 byte item IDs
+	ilocV2 := func() []byte {
+		content := []byte{2, 0, 0, 0, 0x84, 0x00}
+		content = binary.BigEndian.AppendUint32(content, 1)
+		content = binary.BigEndian.AppendUint32(content, 70000) // item ID
+		content = binary.BigEndian.AppendUint16(content, 0)     // construction method
+		content = binary.BigEndian.AppendUint16(content, 0)     // data reference index
+		content = binary.BigEndian.AppendUint16(content, 1)     // extent count
+		content = binary.BigEndian.AppendUint64(content, 1<<33)
+		return binary.BigEndian.AppendUint32(content, 30)
+	}
+	v0 := ilocV0([3]uint32{1, 100, 20}, [3]uint32{2, 300, 40})
+
+	tests := []struct {
+		name       string
+		iloc       []byte
+		itemID     uint32
+		wantOffset int64
+		wantLength int64
+		wantOK     bool
+	}{
+		{"version 0 first item", v0, 1, 100, 20, true},
+		{"version 0 second item", v0, 2, 300, 40, true},
+		{"version 0 missing item", v0, 3, 0, 0, false},
+		{"version 1 adds the base offset to the first extent", ilocV1(0), 7, 1050, 60, true},
+		{"version 1 item stored in the idat box", ilocV1(1), 7, 0, 0, false},
+		{"version 2 large offset", ilocV2(), 70000, 1 << 33, 30, true},
+		{"truncated header", v0[:5], 1, 0, 0, false},
+		{"truncated extent", v0[:len(v0)-2], 2, 0, 0, false},
+		{"empty", nil, 1, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset, length, ok := heifItemLocation(tt.iloc, tt.itemID)
+			if ok != tt.wantOK || offset != tt.wantOffset || length != tt.wantLength {
+				t.Errorf("heifItemLocation = %d, %d, %v, want %d, %d, %v", offset, length, ok, tt.wantOffset, tt.wantLength, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestExifDateTaken(t *testing.T) {
+	tiff := tiffFixture(binary.LittleEndian, testDateTime, testOriginal)
+	files := map[string][]byte{
+		"/photo.jpg":  jpegFixture(exifSegment(tiff)),
+		"/photo.tif":  tiff,
+		"/photo.heic": heifFixture(tiff),
+	}
+
+	memFS := NewMemFS()
+	for name, data := range files {
+		if err := memFS.WriteFile(name, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := memFS.WriteFile("/notes.txt", []byte("II*\x00 is not enough"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := localTime(testOriginal)
+	for name := range files {
+		if got, ok := exifDateTaken(memFS, name); !ok || !got.Equal(want) {
+			t.Errorf("exifDateTaken(%s) = %v, %v, want %v", name, got, ok, want)
+		}
+	}
+	for _, name := range []string{"/notes.txt", "/missing.jpg"} {
+		if got, ok := exifDateTaken(memFS, name); ok {
+			t.Errorf("exifDateTaken(%s) = %v, want no date", name, got)
+		}
+	}
+
+	// Every truncation of a valid file either loses the dates or still reads one of them
+	fallback := localTime(testDateTime)
+	for name, data := range files {
+		for size := range len(data) {
+			truncated := name + ".cut"
+			if err := memFS.WriteFile(truncated, data[:size], 0644); err != nil {
+				t.Fatal(err)
+			}
+			if got, ok := exifDateTaken(memFS, truncated); ok && !got.Equal(want) && !got.Equal(fallback) {
+				t.Errorf("exifDateTaken(%s cut to %d bytes) = %v, want a date of the file or none", name, size, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package organizer
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileSystem is the filesystem an Organizer reads files from and moves them within. Paths are
+// native (filepath) paths, and every method behaves like its os package counterpart
+type FileSystem interface {
+	// Open opens a file for reading
+	Open(name string) (fs.File, error)
+	// CreateTemp creates a new file in dir for writing, see os.CreateTemp
+	CreateTemp(dir, pattern string) (WritableFile, error)
+	Stat(name string) (fs.FileInfo, error)
+	Lstat(name string) (fs.FileInfo, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	MkdirAll(path string, perm fs.FileMode) error
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	Link(oldname, newname string) error
+	Symlink(oldname, newname string) error
+	Chtimes(name string, atime, mtime time.Time) error
+	// WalkDir walks the tree rooted at root in lexical order, see filepath.WalkDir
+	WalkDir(root string, fn fs.WalkDirFunc) error
+}
+
+// sameFile reports whether two FileInfos describe the same file, see os.SameFile. MemFS files
+// are the same when they share a node
+func sameFile(a, b fs.FileInfo) bool {
+	if node, ok := a.Sys().(*memNode); ok {
+		return node == b.Sys()
+	}
+	return os.SameFile(a, b)
+}
+
+// WritableFile is a file opened for writing by FileSystem.CreateTemp
+type WritableFile interface {
+	io.WriteCloser
+	// Name returns the path of the file
+	Name() string
+	// Sync flushes the written content to storage
+	Sync() error
+}
+
+// OSFS is the FileSystem of the operating system, used when Options.FS is nil
+type OSFS struct{}
+
+func (OSFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (OSFS) CreateTemp(dir, pattern string) (WritableFile, error) {
+	return os.CreateTemp(dir, pattern)
+}
+
+func (OSFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (OSFS) Lstat(name string) (fs.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func (OSFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (OSFS) MkdirAll(path string, perm fs.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (OSFS) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (OSFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (OSFS) Link(oldname, newname string) error {
+	return os.Link(oldname, newname)
+}
+
+func (OSFS) Symlink(oldname, newname string) error {
+	return os.Symlink(oldname, newname)
+}
+
+func (OSFS) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+func (OSFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return filepath.WalkDir(root, fn)
+}
+
+// walkDir implements FileSystem.WalkDir on top of Lstat and ReadDir, for filesystems without a
+// native walk. It follows the filepath.WalkDir contract, including fs.SkipDir and fs.SkipAll
+func walkDir(fsys FileSystem, root string, fn fs.WalkDirFunc) error {
+	info, err := fsys.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkDirEntry(fsys, root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if err == fs.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func walkDirEntry(fsys FileSystem, path string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if err == fs.SkipDir && d.IsDir() {
+			// Successfully skipped directory
+			err = nil
+		}
+		return err
+	}
+
+	entries, err := fsys.ReadDir(path)
+	if err != nil {
+		// Second call, to report the ReadDir error
+		err = fn(path, d, err)
+		if err != nil {
+			if err == fs.SkipDir && d.IsDir() {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		if err := walkDirEntry(fsys, filepath.Join(path, entry.Name()), entry, fn); err != nil {
+			if err == fs.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
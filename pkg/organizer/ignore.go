@@ -0,0 +1,272 @@
+package organizer
+
+import (
+	"bufio"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ignoreFileName is the name of the per-directory files listing what to leave alone
+const ignoreFileName = ".organizerignore"
+
+// maxMissingDirs is how many directories without a .organizerignore file an ignoreMatcher
+// remembers before it forgets them all
+const maxMissingDirs = 4096
+
+// ignoreRule is a single gitignore-style pattern
+type ignoreRule struct {
+	pattern string
+	// negate re-includes what an earlier rule ignored
+	negate bool
+	// dirOnly rules, written with a trailing slash, only match directories
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+// parseIgnoreRules parses gitignore-style lines, skipping blank lines and # comments
+func parseIgnoreRules(lines []string) ([]ignoreRule, error) {
+	var rules []ignoreRule
+	for _, line := range lines {
+		rule, ok, err := parseIgnoreRule(line)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// parseIgnoreRule parses a single gitignore-style line. It returns false for blank lines and
+// comments
+func parseIgnoreRule(line string) (ignoreRule, bool, error) {
+	pattern := strings.TrimRight(line, " \t\r")
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return ignoreRule{}, false, nil
+	}
+
+	rule := ignoreRule{pattern: pattern}
+	switch {
+	case strings.HasPrefix(pattern, "!"):
+		rule.negate = true
+		pattern = pattern[1:]
+	case strings.HasPrefix(pattern, `\!`), strings.HasPrefix(pattern, `\#`):
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+
+	// A pattern with a slash other than a trailing one is relative to the directory it applies
+	// to, any other pattern matches at every depth
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	if pattern == "" {
+		return ignoreRule{}, false, fmt.Errorf("invalid ignore pattern %q", line)
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(pattern):
+			b.WriteString(regexp.QuoteMeta(pattern[i+1 : i+2]))
+			i++
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return ignoreRule{}, false, fmt.Errorf("invalid ignore pattern %q: %w", line, err)
+	}
+	rule.re = re
+	return rule, true, nil
+}
+
+// ignoreMatcher decides which paths under a root are left alone. The ignore rules of the config
+// apply first, then the .organizerignore files from the root down to the directory of the path,
+// then the exclude patterns. As in gitignore, the last matching rule wins
+type ignoreMatcher struct {
+	fsys FileSystem
+	root string
+	// configRules and excludeRules are relative to the root
+	configRules  []ignoreRule
+	excludeRules []ignoreRule
+	// warn reports .organizerignore files that cannot be parsed, may be nil
+	warn func(warning string)
+
+	mu sync.Mutex
+	// dirRules caches the rules of the .organizerignore file of each slash separated directory
+	// relative to the root that has one, read on first use
+	dirRules map[string][]ignoreRule
+	// missing remembers the directories found without a .organizerignore file, up to
+	// maxMissingDirs of them
+	missing map[string]bool
+}
+
+func newIgnoreMatcher(fsys FileSystem, root string, configRules, excludeRules []ignoreRule, warn func(string)) *ignoreMatcher {
+	return &ignoreMatcher{
+		fsys:         fsys,
+		root:         filepath.Clean(root),
+		configRules:  configRules,
+		excludeRules: excludeRules,
+		warn:         warn,
+		dirRules:     make(map[string][]ignoreRule),
+		missing:      make(map[string]bool),
+	}
+}
+
+// ignored reports whether the rules match a path under the root. The root itself is never ignored
+func (m *ignoreMatcher) ignored(filePath string, isDir bool) bool {
+	rel, err := filepath.Rel(m.root, filePath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	ignored := false
+	apply := func(rules []ignoreRule, name string) {
+		for _, rule := range rules {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if rule.re.MatchString(name) {
+				ignored = !rule.negate
+			}
+		}
+	}
+
+	apply(m.configRules, rel)
+	dir := ""
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		// Rules of a .organizerignore file are relative to its directory
+		apply(m.rulesOf(dir), strings.Join(parts[i:], "/"))
+		dir = path.Join(dir, parts[i])
+	}
+	apply(m.excludeRules, rel)
+
+	return ignored
+}
+
+// excluded reports whether a path or any directory above it up to the root is ignored, for
+// paths that were not found by walking down from the root
+func (m *ignoreMatcher) excluded(filePath string, isDir bool) bool {
+	rel, err := filepath.Rel(m.root, filePath)
+	if err != nil {
+		return false
+	}
+
+	dir := m.root
+	parts := strings.Split(rel, string(filepath.Separator))
+	for _, part := range parts[:len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		if m.ignored(dir, true) {
+			return true
+		}
+	}
+	return m.ignored(filePath, isDir)
+}
+
+// rulesOf returns the rules of the .organizerignore file in a directory relative to the root
+func (m *ignoreMatcher) rulesOf(dir string) []ignoreRule {
+	m.mu.Lock()
+	rules, loaded := m.dirRules[dir]
+	missing := m.missing[dir]
+	m.mu.Unlock()
+	if loaded || missing {
+		return rules
+	}
+
+	// Read the file without holding the lock, so the scan workers do not wait on each other
+	rules, found, warnings := m.readRules(dir)
+
+	m.mu.Lock()
+	if !found {
+		// Forgetting the directories without a file keeps memory bounded on large trees, at the
+		// cost of looking for their file again
+		if len(m.missing) >= maxMissingDirs {
+			clear(m.missing)
+		}
+		m.missing[dir] = true
+		m.mu.Unlock()
+		return nil
+	}
+	if stored, exists := m.dirRules[dir]; exists {
+		// Another goroutine read the file first, and reported its problems
+		m.mu.Unlock()
+		return stored
+	}
+	m.dirRules[dir] = rules
+	m.mu.Unlock()
+
+	if m.warn != nil {
+		for _, warning := range warnings {
+			m.warn(warning)
+		}
+	}
+	return rules
+}
+
+// readRules reads the .organizerignore file of a directory relative to the root, returning its
+// rules and the lines that cannot be parsed. found is false when the directory has no such file
+func (m *ignoreMatcher) readRules(dir string) (rules []ignoreRule, found bool, warnings []string) {
+	ignoreFile := filepath.Join(m.root, filepath.FromSlash(dir), ignoreFileName)
+	file, err := m.fsys.Open(ignoreFile)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	file.Close()
+
+	for _, line := range lines {
+		rule, ok, err := parseIgnoreRule(line)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", ignoreFile, err))
+			continue
+		}
+		if ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, true, warnings
+}
@@ -0,0 +1,224 @@
+package organizer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseIgnoreRules(t *testing.T) {
+	rules, err := parseIgnoreRules([]string{"# comment", "", "   ", "*.tmp  ", "!keep.tmp", `\!bang`, "build/"})
+	if err != nil {
+		t.Fatalf("parseIgnoreRules: %v", err)
+	}
+
+	want := []struct {
+		pattern         string
+		negate, dirOnly bool
+	}{
+		{"*.tmp", false, false},
+		{"!keep.tmp", true, false},
+		{`\!bang`, false, false},
+		{"build/", false, true},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("parsed %d rules, want %d", len(rules), len(want))
+	}
+	for i, rule := range rules {
+		if rule.pattern != want[i].pattern || rule.negate != want[i].negate || rule.dirOnly != want[i].dirOnly {
+			t.Errorf("rule %d = %q negate %v dirOnly %v, want %+v", i, rule.pattern, rule.negate, rule.dirOnly, want[i])
+		}
+	}
+
+	for _, line := range []string{"/", "!", "!/", "//"} {
+		if _, err := parseIgnoreRules([]string{line}); err == nil {
+			t.Errorf("parseIgnoreRules(%q) returned no error", line)
+		}
+	}
+}
+
+func TestIgnoreMatcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		{"name at the root", []string{"*.tmp"}, "a.tmp", false, true},
+		{"name at any depth", []string{"*.tmp"}, "sub/deep/a.tmp", false, true},
+		{"name must match whole", []string{"*.tmp"}, "a.tmp.txt", false, false},
+		{"star stops at slashes", []string{"sub*"}, "sub/a.txt", false, false},
+		{"question mark", []string{"?.txt"}, "a.txt", false, true},
+		{"question mark matches one character", []string{"?.txt"}, "ab.txt", false, false},
+		{"character class", []string{"[abc].txt"}, "b.txt", false, true},
+		{"character class mismatch", []string{"[abc].txt"}, "d.txt", false, false},
+		{"negated character class", []string{"[!abc].txt"}, "d.txt", false, true},
+		{"negated character class mismatch", []string{"[!abc].txt"}, "a.txt", false, false},
+		{"unclosed bracket is literal", []string{"[a.txt"}, "[a.txt", false, true},
+
+		{"negation re-includes", []string{"*.tmp", "!keep.tmp"}, "keep.tmp", false, false},
+		{"negation at any depth", []string{"*.tmp", "!keep.tmp"}, "sub/keep.tmp", false, false},
+		{"negation leaves others ignored", []string{"*.tmp", "!keep.tmp"}, "other.tmp", false, true},
+		{"last matching rule wins", []string{"!keep.tmp", "*.tmp"}, "keep.tmp", false, true},
+		{"negation alone ignores nothing", []string{"!keep.tmp"}, "keep.tmp", false, false},
+		{"escaped exclamation mark", []string{`\!important.txt`}, "!important.txt", false, true},
+		{"escaped hash", []string{`\#notes`}, "#notes", false, true},
+		{"escaped star", []string{`\*.txt`}, "a.txt", false, false},
+
+		{"directory-only matches a directory", []string{"build/"}, "build", true, true},
+		{"directory-only at any depth", []string{"build/"}, "src/build", true, true},
+		{"directory-only skips files", []string{"build/"}, "build", false, false},
+		{"directory-only negation skips files", []string{"build*", "!build/"}, "build", false, true},
+		{"directory-only negation", []string{"build*", "!build/"}, "build", true, false},
+
+		{"leading slash anchors", []string{"/build"}, "build", true, true},
+		{"leading slash does not match deeper", []string{"/build"}, "src/build", true, false},
+		{"inner slash anchors", []string{"docs/*.pdf"}, "docs/a.pdf", false, true},
+		{"inner slash does not match deeper", []string{"docs/*.pdf"}, "x/docs/a.pdf", false, false},
+		{"inner slash star stops at slashes", []string{"docs/*.pdf"}, "docs/sub/a.pdf", false, false},
+		{"anchored directory-only", []string{"/cache/"}, "cache", true, true},
+		{"anchored directory-only skips deeper", []string{"/cache/"}, "a/cache", true, false},
+
+		{"leading double star", []string{"**/cache"}, "cache", true, true},
+		{"leading double star at depth", []string{"**/cache"}, "a/b/cache", true, true},
+		{"trailing double star", []string{"logs/**"}, "logs/a/b.txt", false, true},
+		{"trailing double star skips the directory", []string{"logs/**"}, "logs", true, false},
+		{"inner double star without directories", []string{"a/**/b"}, "a/b", false, true},
+		{"inner double star with directories", []string{"a/**/b"}, "a/x/y/b", false, true},
+		{"inner double star stays anchored", []string{"a/**/b"}, "z/a/x/b", false, false},
+
+		{"root is never ignored", []string{"*"}, "", true, false},
+	}
+
+	root := "/downloads"
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := parseIgnoreRules(tt.patterns)
+			if err != nil {
+				t.Fatalf("parseIgnoreRules: %v", err)
+			}
+			m := newIgnoreMatcher(NewMemFS(), root, rules, nil, nil)
+			path := root
+			if tt.path != "" {
+				path = root + "/" + tt.path
+			}
+			if got := m.ignored(path, tt.isDir); got != tt.want {
+				t.Errorf("ignored(%q) with %q = %v, want %v", tt.path, tt.patterns, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreMatcherFiles(t *testing.T) {
+	memFS := newTestMemFS(t, map[string]string{
+		"/downloads/.organizerignore":     "*.tmp\n[invalid/\n/\n",
+		"/downloads/sub/.organizerignore": "!keep.tmp\n/local.txt\ncache/\n!notes.log\n",
+	})
+	config, err := parseIgnoreRules([]string{"*.log"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exclude, err := parseIgnoreRules([]string{"sub/keep.tmp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var warnings []string
+	m := newIgnoreMatcher(memFS, "/downloads", config, nil, func(warning string) { warnings = append(warnings, warning) })
+	withExclude := newIgnoreMatcher(memFS, "/downloads", config, exclude, nil)
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"/downloads/a.tmp", false, true},
+		{"/downloads/sub/a.tmp", false, true},
+		// The file of a directory overrides the files above it and the config
+		{"/downloads/sub/keep.tmp", false, false},
+		{"/downloads/keep.tmp", false, true},
+		// Anchored patterns are relative to the directory of their file
+		{"/downloads/sub/local.txt", false, true},
+		{"/downloads/local.txt", false, false},
+		{"/downloads/sub/deeper/local.txt", false, false},
+		{"/downloads/sub/cache", true, true},
+		{"/downloads/cache", true, false},
+		{"/downloads/sub/notes.log", false, false},
+		{"/downloads/notes.log", false, true},
+		// Paths outside the root are never ignored
+		{"/elsewhere/a.tmp", false, false},
+	}
+	for _, tt := range tests {
+		if got := m.ignored(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	// An unclosed bracket is matched literally, only the lone slash is reported
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "/downloads/.organizerignore") {
+		t.Errorf("warnings = %q, want one for the root .organizerignore", warnings)
+	}
+
+	// Exclude patterns apply last
+	if !withExclude.ignored("/downloads/sub/keep.tmp", false) {
+		t.Error("an exclude pattern did not override the .organizerignore negation")
+	}
+
+	// Files below an ignored directory are excluded, even though their own path matches nothing
+	if m.ignored("/downloads/sub/cache/data.bin", false) {
+		t.Error("ignored matched a file below an ignored directory by its own path")
+	}
+	if !m.excluded("/downloads/sub/cache/data.bin", false) {
+		t.Error("excluded missed a file below an ignored directory")
+	}
+	if m.excluded("/downloads/sub/keep.tmp", false) {
+		t.Error("excluded a re-included file")
+	}
+}
+
+func TestIgnoreMatcherCache(t *testing.T) {
+	files := map[string]string{
+		"/downloads/.organizerignore":     "*.tmp\n/\n",
+		"/downloads/sub/.organizerignore": "!keep.tmp\n",
+	}
+	for i := range maxMissingDirs + 10 {
+		files[fmt.Sprintf("/downloads/dir%04d/a.tmp", i)] = "tmp"
+	}
+	memFS := newTestMemFS(t, files)
+
+	var mu sync.Mutex
+	var warnings []string
+	m := newIgnoreMatcher(memFS, "/downloads", nil, nil, func(warning string) {
+		mu.Lock()
+		warnings = append(warnings, warning)
+		mu.Unlock()
+	})
+
+	// The problems of a file are reported once, however many lookups read it concurrently
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range maxMissingDirs + 10 {
+				if !m.ignored(fmt.Sprintf("/downloads/dir%04d/a.tmp", i), false) {
+					t.Errorf("dir%04d/a.tmp is not ignored", i)
+					return
+				}
+			}
+			if m.ignored("/downloads/sub/keep.tmp", false) {
+				t.Error("sub/keep.tmp is ignored")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(warnings) != 1 {
+		t.Errorf("warnings = %q, want one for the lone slash", warnings)
+	}
+	// Only the directories with a file stay cached, the others are forgotten once there are too many
+	if len(m.dirRules) != 2 || len(m.missing) > maxMissingDirs {
+		t.Errorf("cached %d directories with rules and %d without, want 2 and at most %d", len(m.dirRules), len(m.missing), maxMissingDirs)
+	}
+}
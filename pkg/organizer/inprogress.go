@@ -0,0 +1,61 @@
+package organizer
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// InProgressExtensions lists the extensions of downloads and files that are still being written,
+// which are never organized whatever the config says
+var InProgressExtensions = []string{
+	".part",       // Firefox, wget
+	".partial",    // Internet Explorer, Edge
+	".crdownload", // Chrome, Edge
+	".download",   // Safari
+	".opdownload", // Opera
+	".filepart",   // WinSCP
+	".!ut",        // uTorrent
+	".!qb",        // qBittorrent
+	".aria2",      // aria2 control files
+	".tmp",
+	".temp",
+}
+
+// openFilesRefresh is how long the list of files open for writing is reused before it is read again
+const openFilesRefresh = 2 * time.Second
+
+// isInProgress reports whether a file name marks a download or a file being written, including
+// the ~$ lock files Office keeps next to open documents
+func isInProgress(name string) bool {
+	if strings.HasPrefix(name, "~$") {
+		return true
+	}
+	lower := strings.ToLower(name)
+	for _, ext := range InProgressExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// openFiles tracks the files processes hold open for writing
+type openFiles struct {
+	mu      sync.Mutex
+	paths   map[string]bool
+	checked time.Time
+}
+
+// isOpen reports whether a file is held open for writing. The open files are listed again when
+// the last list is older than openFilesRefresh
+func (o *openFiles) isOpen(path string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if time.Since(o.checked) > openFilesRefresh {
+		o.paths = filesOpenForWriting()
+		o.checked = time.Now()
+	}
+	return o.paths[path]
+}
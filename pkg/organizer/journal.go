@@ -0,0 +1,441 @@
+package organizer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// journalExt is the file extension used for run journals
+const journalExt = ".jsonl"
+
+// Journal writes the moves and directory removals of an organize run to disk, one JSON entry per line
+type Journal struct {
+	RunID   string
+	Path    string
+	file    *os.File
+	enc     *json.Encoder
+	entries int
+	mu      sync.Mutex
+}
+
+// DefaultJournalDir returns the directory journals are stored in when none is specified
+func DefaultJournalDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "folder-organizer", "journal"), nil
+}
+
+// NewJournal creates a journal for a new run organizing sourcePath into destPath
+// (an empty destPath organizes in place)
+func NewJournal(journalDir, sourcePath, destPath string) (*Journal, error) {
+	if err := os.MkdirAll(journalDir, 0755); err != nil {
+		return nil, fmt.Errorf("create journal directory: %w", err)
+	}
+
+	absSource, err := filepath.Abs(sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("resolve source path: %w", err)
+	}
+	absDest := absSource
+	if destPath != "" {
+		if absDest, err = filepath.Abs(destPath); err != nil {
+			return nil, fmt.Errorf("resolve destination path: %w", err)
+		}
+	}
+
+	// Run IDs are timestamps, with a counter appended if two runs start in the same second
+	started := time.Now()
+	runID := started.Format("20060102-150405")
+	path := filepath.Join(journalDir, runID+journalExt)
+	for counter := 1; ; counter++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		runID = fmt.Sprintf("%s-%d", started.Format("20060102-150405"), counter)
+		path = filepath.Join(journalDir, runID+journalExt)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("create journal: %w", err)
+	}
+
+	journal := &Journal{
+		RunID: runID,
+		Path:  path,
+		file:  file,
+		enc:   json.NewEncoder(file),
+	}
+
+	if err := journal.enc.Encode(JournalEntry{
+		Action: JournalActionStart,
+		Path:   absSource,
+		Target: absDest,
+		Time:   started,
+	}); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("write journal: %w", err)
+	}
+
+	return journal, nil
+}
+
+// RecordMove appends a completed move to the journal. For deduplicated files the target is the
+// identical copy that was kept
+func (j *Journal) RecordMove(move FileMove, size int64, modTime time.Time) error {
+	action := JournalActionMove
+	if move.Action == MoveActionDedupe {
+		action = JournalActionDedupe
+	}
+	mode := move.Mode
+	if mode == ModeMove {
+		mode = ""
+	}
+
+	return j.write(JournalEntry{
+		Action:   action,
+		Source:   absPath(move.Source),
+		Target:   absPath(move.Target),
+		Mode:     mode,
+		Replaced: move.Action == MoveActionOverwrite,
+		Size:     size,
+		ModTime:  modTime,
+		Time:     time.Now(),
+	})
+}
+
+// RecordRemovedDir appends a directory removed during cleanup to the journal
+func (j *Journal) RecordRemovedDir(path string) error {
+	return j.write(JournalEntry{
+		Action: JournalActionRemoveDir,
+		Path:   absPath(path),
+		Time:   time.Now(),
+	})
+}
+
+func (j *Journal) write(entry JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.enc.Encode(entry); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	j.entries++
+	return nil
+}
+
+// Entries returns the number of changes recorded so far
+func (j *Journal) Entries() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.entries
+}
+
+// Close closes the journal, removing it if the run did not change anything
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.file.Close(); err != nil {
+		return err
+	}
+	if j.entries == 0 {
+		return os.Remove(j.Path)
+	}
+	return nil
+}
+
+// ListRuns returns a summary of every journaled run, newest first
+func ListRuns(journalDir string) ([]RunSummary, error) {
+	files, err := os.ReadDir(journalDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var runs []RunSummary
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != journalExt {
+			continue
+		}
+
+		runID := strings.TrimSuffix(file.Name(), journalExt)
+		entries, err := readJournal(filepath.Join(journalDir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read journal %s: %w", runID, err)
+		}
+		runs = append(runs, summarizeRun(runID, entries))
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Started.After(runs[j].Started)
+	})
+
+	return runs, nil
+}
+
+func summarizeRun(runID string, entries []JournalEntry) RunSummary {
+	summary := RunSummary{RunID: runID}
+	for _, entry := range entries {
+		switch entry.Action {
+		case JournalActionStart:
+			summary.Path = entry.Path
+			summary.Dest = entry.Target
+			summary.Started = entry.Time
+		case JournalActionMove, JournalActionDedupe:
+			summary.Moves++
+		case JournalActionUndoEntry:
+			summary.PartlyUndone = true
+		case JournalActionUndo:
+			summary.Undone = true
+		}
+	}
+	if summary.Undone {
+		summary.PartlyUndone = false
+	}
+	return summary
+}
+
+// undoKey identifies the move of a journal entry
+func undoKey(entry JournalEntry) string {
+	return entry.Source + "\x00" + entry.Target
+}
+
+// readJournal reads every entry of a journal file
+func readJournal(path string) ([]JournalEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			// A run interrupted mid-write can leave a truncated last line
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// UndoRun reverses a journaled run: directories removed during cleanup are recreated and every
+// moved file is put back, newest move first. Copies and links created by the run are removed, and
+// deleted duplicates are restored from the identical copy that was kept. Files that changed since
+// the move are left in place and reported.
+// Every move put back is recorded in the journal, and the run is only marked as undone once all of
+// them are, so running UndoRun again after fixing the reported problems finishes the undo.
+// An empty runID undoes the most recent run that has not been undone yet
+func UndoRun(journalDir, runID string) (*UndoResult, error) {
+	return undoRun(OSFS{}, journalDir, runID)
+}
+
+// undoRun undoes a run whose files are on fsys. The journal itself is always on the local disk
+func undoRun(fsys FileSystem, journalDir, runID string) (*UndoResult, error) {
+	if runID == "" {
+		runs, err := ListRuns(journalDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, run := range runs {
+			if !run.Undone {
+				runID = run.RunID
+				break
+			}
+		}
+		if runID == "" {
+			return nil, errors.New("no runs left to undo")
+		}
+	}
+
+	path := filepath.Join(journalDir, runID+journalExt)
+	entries, err := readJournal(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no journal found for run %s", runID)
+		}
+		return nil, fmt.Errorf("read journal: %w", err)
+	}
+
+	summary := summarizeRun(runID, entries)
+	if summary.Undone {
+		return nil, fmt.Errorf("run %s has already been undone", runID)
+	}
+	if summary.Dest == "" {
+		summary.Dest = summary.Path
+	}
+
+	// Moves put back by an earlier undo that did not complete
+	undone := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Action == JournalActionUndoEntry {
+			undone[undoKey(entry)] = true
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open journal: %w", err)
+	}
+	defer file.Close()
+	enc := json.NewEncoder(file)
+
+	result := &UndoResult{RunID: runID}
+
+	// markUndone records a move that was put back
+	markUndone := func(entry JournalEntry) {
+		if err := enc.Encode(JournalEntry{
+			Action: JournalActionUndoEntry,
+			Source: entry.Source,
+			Target: entry.Target,
+			Time:   time.Now(),
+		}); err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("write journal: %w", err))
+		}
+	}
+
+	// Recreate directories removed by cleanup, so moved files can return to their original folders
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Action != JournalActionRemoveDir {
+			continue
+		}
+		if _, err := fsys.Stat(entry.Path); err == nil {
+			continue
+		}
+		if err := fsys.MkdirAll(entry.Path, 0755); err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("recreate directory %s: %w", entry.Path, err))
+			continue
+		}
+		result.RecreatedDirs++
+	}
+
+	// Put the files back, most recent move first
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Action != JournalActionMove && entry.Action != JournalActionDedupe || undone[undoKey(entry)] {
+			continue
+		}
+
+		info, err := fsys.Lstat(entry.Target)
+		if err != nil {
+			result.Missing = append(result.Missing, entry.Target)
+			continue
+		}
+		if info.Size() != entry.Size || !info.ModTime().Equal(entry.ModTime) {
+			result.Changed = append(result.Changed, entry.Target)
+			continue
+		}
+
+		if entry.Mode != "" && entry.Mode != ModeMove {
+			// The source was never touched, only remove the copy or link
+			if err := fsys.Remove(entry.Target); err != nil {
+				result.Failed = append(result.Failed, entry.Target)
+				continue
+			}
+			result.RemovedFiles++
+			markUndone(entry)
+			if entry.Replaced {
+				result.Replaced = append(result.Replaced, entry.Target)
+			}
+			removeEmptyParents(fsys, filepath.Dir(entry.Target), summary.Dest)
+			continue
+		}
+
+		if _, err := fsys.Stat(entry.Source); err == nil {
+			result.Conflicts = append(result.Conflicts, entry.Source)
+			continue
+		}
+
+		if err := fsys.MkdirAll(filepath.Dir(entry.Source), 0755); err != nil {
+			result.Failed = append(result.Failed, entry.Target)
+			continue
+		}
+
+		if entry.Action == JournalActionDedupe {
+			// The duplicate was deleted, recreate it from the copy that was kept. The journal only
+			// knows the modification time of the kept copy, so the duplicate's own is not restored
+			if err := copyFile(context.Background(), fsys, entry.Target, entry.Source); err != nil {
+				result.Failed = append(result.Failed, entry.Target)
+				continue
+			}
+			result.RestoredFiles++
+			markUndone(entry)
+			continue
+		}
+
+		if err := fsys.Rename(entry.Target, entry.Source); err != nil {
+			if err := moveFileFallback(context.Background(), fsys, entry.Target, entry.Source); err != nil {
+				result.Failed = append(result.Failed, entry.Target)
+				continue
+			}
+		}
+		result.RestoredFiles++
+		markUndone(entry)
+		if entry.Replaced {
+			result.Replaced = append(result.Replaced, entry.Target)
+		}
+
+		// Drop the category folders the run created once they are empty again
+		removeEmptyParents(fsys, filepath.Dir(entry.Target), summary.Dest)
+	}
+
+	// Leave the run open for another attempt while some of its changes could not be undone
+	if !result.Complete() {
+		return result, nil
+	}
+	if err := enc.Encode(JournalEntry{
+		Action: JournalActionUndo,
+		Time:   time.Now(),
+	}); err != nil {
+		return result, fmt.Errorf("mark run as undone: %w", err)
+	}
+
+	return result, nil
+}
+
+// removeEmptyParents removes dir and its parents while they are empty, stopping at root. Nothing
+// outside root is removed
+func removeEmptyParents(fsys FileSystem, dir, root string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); ; dir = filepath.Dir(dir) {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return
+		}
+		if err := fsys.Remove(dir); err != nil {
+			return
+		}
+	}
+}
+
+// absPath returns the absolute form of path, falling back to path itself
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
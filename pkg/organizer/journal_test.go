@@ -0,0 +1,173 @@
+package organizer
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+// executeJournaled organizes memFS with a journal in journalDir, cleaning up empty directories
+// afterwards, and returns the ID of the run
+func executeJournaled(t *testing.T, org *Organizer, journalDir string, opts Options) string {
+	t.Helper()
+	journal, err := NewJournal(journalDir, opts.SourcePath, opts.DestPath)
+	if err != nil {
+		t.Fatalf("NewJournal: %v", err)
+	}
+	opts.Journal = journal
+
+	stats, err := org.Execute(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	opts.Stats = stats
+	if _, err := org.Cleanup(opts); err != nil {
+		t.Fatalf("Cleanup: %v", err)
+	}
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return journal.RunID
+}
+
+func TestUndoRunMemFS(t *testing.T) {
+	org := newTestOrganizer(t, `{"categories": {"images": [".jpg", ".png"]}}`)
+	journalDir := t.TempDir()
+	before := map[string]string{
+		"/downloads/photo.jpg":      "moved",
+		"/downloads/trip/a.jpg":     "renamed",
+		"/downloads/trip/shot.png":  "moved from a folder left empty",
+		"/sorted/images/jpg/a.jpg":  "already there",
+		"/sorted/images/keep/x.txt": "unrelated",
+	}
+	memFS := newTestMemFS(t, before)
+	opts := Options{SourcePath: "/downloads", DestPath: "/sorted", NumWorkers: 2, Recursive: true, FS: memFS}
+
+	runID := executeJournaled(t, org, journalDir, opts)
+	organized := []string{
+		"/sorted/images/jpg/a.jpg",
+		"/sorted/images/jpg/a_1.jpg",
+		"/sorted/images/jpg/photo.jpg",
+		"/sorted/images/keep/x.txt",
+		"/sorted/images/png/shot.png",
+	}
+	if got := memFiles(t, memFS); !slices.Equal(got, organized) {
+		t.Fatalf("files after the run = %v, want %v", got, organized)
+	}
+	if _, err := memFS.Stat("/downloads/trip"); err == nil {
+		t.Fatal("cleanup left /downloads/trip behind")
+	}
+
+	result, err := undoRun(memFS, journalDir, runID)
+	if err != nil {
+		t.Fatalf("undoRun: %v", err)
+	}
+	if !result.Complete() || result.RestoredFiles != 3 || result.RecreatedDirs != 1 {
+		t.Errorf("undo result = %+v, want 3 files restored and 1 directory recreated", result)
+	}
+
+	want := []string{"/downloads/photo.jpg", "/downloads/trip/a.jpg", "/downloads/trip/shot.png", "/sorted/images/jpg/a.jpg", "/sorted/images/keep/x.txt"}
+	if got := memFiles(t, memFS); !slices.Equal(got, want) {
+		t.Errorf("files after the undo = %v, want %v", got, want)
+	}
+	for path, content := range before {
+		if data, err := memFS.ReadFile(path); err != nil || string(data) != content {
+			t.Errorf("%s after the undo = %q, %v, want %q", path, data, err, content)
+		}
+	}
+	// The folder the run created is removed again, those holding other files are kept
+	if _, err := memFS.Stat("/sorted/images/png"); err == nil {
+		t.Error("the undo left the emptied /sorted/images/png behind")
+	}
+
+	if _, err := undoRun(memFS, journalDir, runID); err == nil {
+		t.Error("undoing the run twice returned no error")
+	}
+}
+
+func TestUndoRunMemFSOverwrite(t *testing.T) {
+	org := newTestOrganizer(t, `{"categories": {"images": [".jpg"]}}`)
+	journalDir := t.TempDir()
+	memFS := newTestMemFS(t, map[string]string{
+		"/downloads/photo.jpg":         "new",
+		"/sorted/images/jpg/photo.jpg": "old",
+	})
+	opts := Options{SourcePath: "/downloads", DestPath: "/sorted", NumWorkers: 1, OnConflict: ConflictOverwrite, FS: memFS}
+
+	runID := executeJournaled(t, org, journalDir, opts)
+	if data, _ := memFS.ReadFile("/sorted/images/jpg/photo.jpg"); string(data) != "new" {
+		t.Fatalf("target after the run = %q, want the overwriting file", data)
+	}
+
+	result, err := undoRun(memFS, journalDir, runID)
+	if err != nil {
+		t.Fatalf("undoRun: %v", err)
+	}
+	// The overwritten content is gone, the undo says so and puts the moved file back
+	if !result.Complete() || result.RestoredFiles != 1 || !slices.Equal(result.Replaced, []string{"/sorted/images/jpg/photo.jpg"}) {
+		t.Errorf("undo result = %+v, want the file restored and the target reported as replaced", result)
+	}
+	if data, err := memFS.ReadFile("/downloads/photo.jpg"); err != nil || string(data) != "new" {
+		t.Errorf("restored file = %q, %v", data, err)
+	}
+}
+
+func TestUndoRunMemFSPartial(t *testing.T) {
+	org := newTestOrganizer(t, `{"categories": {"images": [".jpg"]}}`)
+	journalDir := t.TempDir()
+	memFS := newTestMemFS(t, map[string]string{
+		"/downloads/a.jpg": "a",
+		"/downloads/b.jpg": "b",
+	})
+	runID := executeJournaled(t, org, journalDir, Options{SourcePath: "/downloads", NumWorkers: 1, FS: memFS})
+
+	// A new file where a.jpg came from keeps it from being put back
+	if err := memFS.WriteFile("/downloads/a.jpg", []byte("other"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := undoRun(memFS, journalDir, runID)
+	if err != nil {
+		t.Fatalf("undoRun: %v", err)
+	}
+	if result.Complete() || result.RestoredFiles != 1 || !slices.Equal(result.Conflicts, []string{"/downloads/a.jpg"}) {
+		t.Fatalf("undo result = %+v, want b.jpg restored and a.jpg in conflict", result)
+	}
+
+	// Once the conflict is gone, undoing again finishes the job
+	if err := memFS.Remove("/downloads/a.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	result, err = undoRun(memFS, journalDir, runID)
+	if err != nil {
+		t.Fatalf("second undoRun: %v", err)
+	}
+	if !result.Complete() || result.RestoredFiles != 1 {
+		t.Errorf("second undo result = %+v, want a.jpg restored", result)
+	}
+	if got, want := memFiles(t, memFS), []string{"/downloads/a.jpg", "/downloads/b.jpg"}; !slices.Equal(got, want) {
+		t.Errorf("files after the undo = %v, want %v", got, want)
+	}
+}
+
+func TestRemoveEmptyParents(t *testing.T) {
+	memFS := newTestMemFS(t, map[string]string{"/data/keep.txt": "keep"})
+	for _, dir := range []string{"/data/dl/a/b", "/data/dl2/c"} {
+		if err := memFS.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removeEmptyParents(memFS, "/data/dl/a/b", "/data/dl")
+	if _, err := memFS.Stat("/data/dl/a"); err == nil {
+		t.Error("removeEmptyParents left an empty directory below the root")
+	}
+	if _, err := memFS.Stat("/data/dl"); err != nil {
+		t.Errorf("removeEmptyParents removed the root: %v", err)
+	}
+
+	// A sibling sharing the root's name as a prefix is outside it
+	removeEmptyParents(memFS, "/data/dl2/c", "/data/dl")
+	if _, err := memFS.Stat("/data/dl2/c"); err != nil {
+		t.Errorf("removeEmptyParents removed a directory outside the root: %v", err)
+	}
+}
@@ -0,0 +1,503 @@
+package organizer
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSymlinkDepth is how many symbolic links MemFS follows before giving up on a path
+const maxSymlinkDepth = 40
+
+// MemFS is an in-memory FileSystem, for trying configurations and embedding the organizer
+// without touching the disk. Relative paths are resolved against the filesystem root. It is
+// safe for concurrent use
+type MemFS struct {
+	mu    sync.Mutex
+	nodes map[string]*memNode
+	// children maps each directory path to the paths directly inside it
+	children map[string]map[string]bool
+	// tempCounter makes the names created by CreateTemp unique
+	tempCounter int
+}
+
+// memNode is a file, directory or symbolic link of a MemFS. Hard links share the same node
+type memNode struct {
+	mode    fs.FileMode
+	modTime time.Time
+	data    []byte
+	// target is where a symbolic link points
+	target string
+}
+
+// NewMemFS returns an empty in-memory filesystem holding only its root directory
+func NewMemFS() *MemFS {
+	m := &MemFS{nodes: make(map[string]*memNode), children: make(map[string]map[string]bool)}
+	m.nodes[m.clean(string(filepath.Separator))] = &memNode{mode: fs.ModeDir | 0755, modTime: time.Now()}
+	return m
+}
+
+// WriteFile creates (or truncates) a file holding data, see os.WriteFile. The parent directory
+// must exist
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = m.clean(name)
+	if node, err := m.resolve(name, true); err == nil {
+		if node.mode.IsDir() {
+			return &fs.PathError{Op: "open", Path: name, Err: errIsDir}
+		}
+		node.data = bytes.Clone(data)
+		node.modTime = time.Now()
+		return nil
+	}
+	if err := m.checkParent("open", name); err != nil {
+		return err
+	}
+
+	m.add(name, &memNode{mode: perm.Perm(), modTime: time.Now(), data: bytes.Clone(data)})
+	return nil
+}
+
+// ReadFile returns the content of a file, see os.ReadFile
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, err := m.resolve(m.clean(name), true)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errIsDir}
+	}
+	return bytes.Clone(node.data), nil
+}
+
+func (m *MemFS) Open(name string) (fs.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = m.clean(name)
+	node, err := m.resolve(name, true)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	if node.mode.IsDir() {
+		return &memDir{info: m.info(name, node)}, nil
+	}
+	return &memReadFile{Reader: bytes.NewReader(bytes.Clone(node.data)), info: m.info(name, node)}, nil
+}
+
+func (m *MemFS) CreateTemp(dir, pattern string) (WritableFile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prefix, suffix := pattern, ""
+	if i := strings.LastIndex(pattern, "*"); i >= 0 {
+		prefix, suffix = pattern[:i], pattern[i+1:]
+	}
+
+	for {
+		m.tempCounter++
+		name := m.clean(filepath.Join(dir, prefix+strconv.Itoa(m.tempCounter)+suffix))
+		if _, exists := m.nodes[name]; exists {
+			continue
+		}
+		if err := m.checkParent("createtemp", name); err != nil {
+			return nil, err
+		}
+
+		node := &memNode{mode: 0600, modTime: time.Now()}
+		m.add(name, node)
+		return &memWriteFile{fs: m, name: name, node: node}, nil
+	}
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	return m.stat("stat", name, true)
+}
+
+func (m *MemFS) Lstat(name string) (fs.FileInfo, error) {
+	return m.stat("lstat", name, false)
+}
+
+func (m *MemFS) stat(op, name string, follow bool) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = m.clean(name)
+	node, err := m.resolve(name, follow)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return m.info(name, node), nil
+}
+
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = m.clean(name)
+	node, err := m.resolve(name, true)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if !node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdirent", Path: name, Err: errNotDir}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(m.children[name]))
+	for path := range m.children[name] {
+		entries = append(entries, fs.FileInfoToDirEntry(m.info(path, m.nodes[path])))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+func (m *MemFS) MkdirAll(path string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path = m.clean(path)
+	if node, err := m.resolve(path, true); err == nil {
+		if !node.mode.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: path, Err: errNotDir}
+		}
+		return nil
+	}
+
+	// Create the missing parents first, from the root down
+	var missing []string
+	for dir := path; ; dir = filepath.Dir(dir) {
+		node, err := m.resolve(dir, true)
+		if err == nil {
+			if !node.mode.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: dir, Err: errNotDir}
+			}
+			break
+		}
+		missing = append(missing, dir)
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		m.add(missing[i], &memNode{mode: fs.ModeDir | perm.Perm(), modTime: time.Now()})
+	}
+	return nil
+}
+
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	oldpath, newpath = m.clean(oldpath), m.clean(newpath)
+	node, exists := m.nodes[oldpath]
+	if !exists {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrNotExist}
+	}
+	if oldpath == newpath {
+		return nil
+	}
+	if err := m.checkParent("rename", newpath); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	if existing, exists := m.nodes[newpath]; exists {
+		if existing.mode.IsDir() != node.mode.IsDir() || (existing.mode.IsDir() && m.hasChildren(newpath)) {
+			return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrExist}
+		}
+	}
+	if node.mode.IsDir() && strings.HasPrefix(newpath, oldpath+string(filepath.Separator)) {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrInvalid}
+	}
+
+	// Move the node along with everything below it
+	if _, exists := m.nodes[newpath]; exists {
+		m.delete(newpath)
+	}
+	m.move(oldpath, newpath)
+	return nil
+}
+
+// move moves the node at a cleaned path and everything below it to another path. The mutex
+// must be held
+func (m *MemFS) move(oldpath, newpath string) {
+	for child := range m.children[oldpath] {
+		m.move(child, filepath.Join(newpath, filepath.Base(child)))
+	}
+	node := m.nodes[oldpath]
+	m.delete(oldpath)
+	m.add(newpath, node)
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = m.clean(name)
+	node, exists := m.nodes[name]
+	if !exists {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if node.mode.IsDir() && m.hasChildren(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+	}
+	m.delete(name)
+	return nil
+}
+
+func (m *MemFS) Link(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	oldname, newname = m.clean(oldname), m.clean(newname)
+	node, exists := m.nodes[oldname]
+	if !exists {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: fs.ErrNotExist}
+	}
+	if node.mode.IsDir() {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: fs.ErrPermission}
+	}
+	if err := m.checkNew(newname); err != nil {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: err}
+	}
+
+	m.add(newname, node)
+	return nil
+}
+
+func (m *MemFS) Symlink(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	newname = m.clean(newname)
+	if err := m.checkNew(newname); err != nil {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: err}
+	}
+
+	m.add(newname, &memNode{mode: fs.ModeSymlink | 0777, modTime: time.Now(), target: oldname})
+	return nil
+}
+
+func (m *MemFS) Chtimes(name string, atime, mtime time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, err := m.resolve(m.clean(name), true)
+	if err != nil {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: err}
+	}
+	node.modTime = mtime
+	return nil
+}
+
+func (m *MemFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return walkDir(m, root, fn)
+}
+
+// clean turns name into the key of its node: an absolute, cleaned path
+func (m *MemFS) clean(name string) string {
+	if !filepath.IsAbs(name) {
+		name = string(filepath.Separator) + name
+	}
+	return filepath.Clean(name)
+}
+
+// resolve returns the node at a cleaned path, following symbolic links when follow is set.
+// The mutex must be held
+func (m *MemFS) resolve(name string, follow bool) (*memNode, error) {
+	for depth := 0; ; depth++ {
+		node, exists := m.nodes[name]
+		if !exists {
+			return nil, fs.ErrNotExist
+		}
+		if !follow || node.mode&fs.ModeSymlink == 0 {
+			return node, nil
+		}
+		if depth == maxSymlinkDepth {
+			return nil, errTooManyLinks
+		}
+
+		target := node.target
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(name), target)
+		}
+		name = m.clean(target)
+	}
+}
+
+// checkParent returns an error unless the parent directory of a cleaned path exists. The mutex
+// must be held
+func (m *MemFS) checkParent(op, name string) error {
+	parent, err := m.resolve(filepath.Dir(name), true)
+	if err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if !parent.mode.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: errNotDir}
+	}
+	return nil
+}
+
+// checkNew returns an error unless a new link can be created at a cleaned path. The mutex must
+// be held
+func (m *MemFS) checkNew(name string) error {
+	if _, exists := m.nodes[name]; exists {
+		return fs.ErrExist
+	}
+	if err := m.checkParent("link", name); err != nil {
+		return err.(*fs.PathError).Err
+	}
+	return nil
+}
+
+// hasChildren reports whether anything lives below a cleaned directory path. The mutex must be held
+func (m *MemFS) hasChildren(dir string) bool {
+	return len(m.children[dir]) > 0
+}
+
+// add stores a node at a cleaned path and indexes it in its parent directory. The mutex must be held
+func (m *MemFS) add(name string, node *memNode) {
+	m.nodes[name] = node
+	parent := filepath.Dir(name)
+	if parent == name {
+		return
+	}
+	if m.children[parent] == nil {
+		m.children[parent] = make(map[string]bool)
+	}
+	m.children[parent][name] = true
+}
+
+// delete removes the node at a cleaned path along with its index entries. The mutex must be held
+func (m *MemFS) delete(name string) {
+	delete(m.nodes, name)
+	delete(m.children, name)
+	if parent := filepath.Dir(name); parent != name {
+		delete(m.children[parent], name)
+	}
+}
+
+// info describes a node. The mutex must be held
+func (m *MemFS) info(name string, node *memNode) fs.FileInfo {
+	info := &memFileInfo{name: filepath.Base(name), mode: node.mode, modTime: node.modTime, node: node}
+	switch {
+	case node.mode&fs.ModeSymlink != 0:
+		info.size = int64(len(node.target))
+	case !node.mode.IsDir():
+		info.size = int64(len(node.data))
+	}
+	return info
+}
+
+// MemFS errors, matching the messages of their syscall counterparts
+var (
+	errIsDir        = memError("is a directory")
+	errNotDir       = memError("not a directory")
+	errNotEmpty     = memError("directory not empty")
+	errTooManyLinks = memError("too many levels of symbolic links")
+)
+
+type memError string
+
+func (e memError) Error() string {
+	return string(e)
+}
+
+// memFileInfo implements fs.FileInfo for MemFS nodes
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	// node identifies the file, hard links share it
+	node *memNode
+}
+
+func (i *memFileInfo) Name() string       { return i.name }
+func (i *memFileInfo) Size() int64        { return i.size }
+func (i *memFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i *memFileInfo) ModTime() time.Time { return i.modTime }
+func (i *memFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memFileInfo) Sys() any           { return i.node }
+
+// memReadFile is a MemFS file opened for reading, holding a snapshot of its content
+type memReadFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *memReadFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *memReadFile) Close() error {
+	return nil
+}
+
+// memDir is a MemFS directory opened for reading, which cannot be read as a file
+type memDir struct {
+	info fs.FileInfo
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *memDir) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: errIsDir}
+}
+
+func (d *memDir) Close() error {
+	return nil
+}
+
+// memWriteFile is a MemFS file opened for writing by CreateTemp
+type memWriteFile struct {
+	fs     *MemFS
+	name   string
+	node   *memNode
+	closed bool
+}
+
+func (f *memWriteFile) Name() string {
+	return f.name
+}
+
+func (f *memWriteFile) Write(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if f.closed {
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.node.data = append(f.node.data, p...)
+	f.node.modTime = time.Now()
+	return len(p), nil
+}
+
+func (f *memWriteFile) Sync() error {
+	return nil
+}
+
+func (f *memWriteFile) Close() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	return nil
+}
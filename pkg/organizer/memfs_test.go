@@ -0,0 +1,152 @@
+package organizer
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newTestMemFS returns a MemFS holding the given files, creating their parent directories
+func newTestMemFS(t *testing.T, files map[string]string) *MemFS {
+	t.Helper()
+	memFS := NewMemFS()
+	for name, content := range files {
+		if err := memFS.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := memFS.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return memFS
+}
+
+// dirNames returns the names ReadDir lists for a directory
+func dirNames(t *testing.T, fsys FileSystem, dir string) []string {
+	t.Helper()
+	entries, err := fsys.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir(%s): %v", dir, err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestMemFSReadDir(t *testing.T) {
+	memFS := newTestMemFS(t, map[string]string{
+		"/data/b.txt":       "b",
+		"/data/a.txt":       "a",
+		"/data/sub/c.txt":   "c",
+		"/data/sub/d/e.txt": "e",
+		"/other/f.txt":      "f",
+	})
+
+	if got, want := dirNames(t, memFS, "/data"), []string{"a.txt", "b.txt", "sub"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadDir(/data) = %v, want %v", got, want)
+	}
+	if got, want := dirNames(t, memFS, "/"), []string{"data", "other"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadDir(/) = %v, want %v", got, want)
+	}
+	if _, err := memFS.ReadDir("/data/a.txt"); err == nil {
+		t.Error("ReadDir of a file returned no error")
+	}
+	if _, err := memFS.ReadDir("/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadDir of a missing directory: got %v, want ErrNotExist", err)
+	}
+}
+
+func TestMemFSRenameDirectory(t *testing.T) {
+	memFS := newTestMemFS(t, map[string]string{
+		"/data/sub/c.txt":   "c",
+		"/data/sub/d/e.txt": "e",
+	})
+
+	if err := memFS.Rename("/data/sub", "/moved"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if got, want := dirNames(t, memFS, "/moved"), []string{"c.txt", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadDir(/moved) = %v, want %v", got, want)
+	}
+	if data, err := memFS.ReadFile("/moved/d/e.txt"); err != nil || string(data) != "e" {
+		t.Errorf("ReadFile(/moved/d/e.txt) = %q, %v", data, err)
+	}
+	if got := dirNames(t, memFS, "/data"); len(got) != 0 {
+		t.Errorf("ReadDir(/data) after the move = %v, want it empty", got)
+	}
+	if _, err := memFS.Stat("/data/sub/d/e.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat of a moved file: got %v, want ErrNotExist", err)
+	}
+	if err := memFS.Rename("/moved", "/moved/inside"); err == nil {
+		t.Error("Rename of a directory into itself returned no error")
+	}
+}
+
+func TestMemFSRemove(t *testing.T) {
+	memFS := newTestMemFS(t, map[string]string{"/data/sub/c.txt": "c"})
+
+	if err := memFS.Remove("/data/sub"); err == nil {
+		t.Error("Remove of a non-empty directory returned no error")
+	}
+	if err := memFS.Remove("/data/sub/c.txt"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := memFS.Remove("/data/sub"); err != nil {
+		t.Fatalf("Remove of the emptied directory: %v", err)
+	}
+	if got := dirNames(t, memFS, "/data"); len(got) != 0 {
+		t.Errorf("ReadDir(/data) = %v, want it empty", got)
+	}
+}
+
+func TestMemFSOpenDirectory(t *testing.T) {
+	memFS := newTestMemFS(t, map[string]string{"/data/a.txt": "a"})
+
+	file, err := memFS.Open("/data")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Read(make([]byte, 1)); err == nil {
+		t.Error("Read of a directory returned no error")
+	}
+	if _, ok := file.(io.ReaderAt); ok {
+		t.Error("an opened directory implements io.ReaderAt")
+	}
+	if _, ok := file.(io.Seeker); ok {
+		t.Error("an opened directory implements io.Seeker")
+	}
+	if info, err := file.Stat(); err != nil || !info.IsDir() {
+		t.Errorf("Stat of an opened directory = %v, %v", info, err)
+	}
+}
+
+func TestMemFSLinks(t *testing.T) {
+	memFS := newTestMemFS(t, map[string]string{"/data/a.txt": "a"})
+
+	if err := memFS.Link("/data/a.txt", "/data/hard.txt"); err != nil {
+		t.Fatalf("Link: %v", err)
+	}
+	if err := memFS.Symlink("/data/a.txt", "/data/soft.txt"); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	original, _ := memFS.Stat("/data/a.txt")
+	hard, _ := memFS.Stat("/data/hard.txt")
+	soft, _ := memFS.Stat("/data/soft.txt")
+	if !sameFile(original, hard) || !sameFile(original, soft) {
+		t.Error("links do not describe the same file as their target")
+	}
+	if info, err := memFS.Lstat("/data/soft.txt"); err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		t.Errorf("Lstat of a symbolic link = %v, %v", info, err)
+	}
+	if err := memFS.Symlink("/data/a.txt", "/data/hard.txt"); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("Symlink over an existing file: got %v, want ErrExist", err)
+	}
+}
@@ -0,0 +1,69 @@
+//go:build linux
+
+package organizer
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// filesOpenForWriting lists the files held open for writing by the processes the current user
+// can inspect, from the file descriptors in /proc
+func filesOpenForWriting() map[string]bool {
+	open := make(map[string]bool)
+
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return open
+	}
+	for _, proc := range procs {
+		if _, err := strconv.Atoi(proc.Name()); err != nil {
+			continue
+		}
+
+		fdDir := filepath.Join("/proc", proc.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			// Processes of other users cannot be inspected
+			continue
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			// Sockets, pipes and other descriptors that are not files have no absolute path
+			if err != nil || !strings.HasPrefix(target, "/") || open[target] {
+				continue
+			}
+			if openedForWriting(filepath.Join("/proc", proc.Name(), "fdinfo", fd.Name())) {
+				open[target] = true
+			}
+		}
+	}
+	return open
+}
+
+// openedForWriting reports whether the open flags in an fdinfo file allow writing
+func openedForWriting(fdinfo string) bool {
+	file, err := os.Open(fdinfo)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		value, found := strings.CutPrefix(scanner.Text(), "flags:")
+		if !found {
+			continue
+		}
+		flags, err := strconv.ParseUint(strings.TrimSpace(value), 8, 64)
+		if err != nil {
+			return false
+		}
+		return flags&syscall.O_ACCMODE != syscall.O_RDONLY
+	}
+	return false
+}
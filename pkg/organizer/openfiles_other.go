@@ -0,0 +1,8 @@
+//go:build !linux
+
+package organizer
+
+// filesOpenForWriting is only implemented on Linux, other systems report no open files
+func filesOpenForWriting() map[string]bool {
+	return nil
+}
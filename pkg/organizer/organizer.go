@@ -0,0 +1,662 @@
+// Package organizer sorts files into category folders based on their extensions. Load a
+// configuration with LoadConfig or ParseConfig, build an Organizer from it with New, then Plan,
+// Execute or Watch runs. Runs can be journaled with a Journal and reverted with UndoRun
+package organizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Organizer sorts files into category folders according to a parsed configuration. It holds no
+// per-run state, so one Organizer can plan and execute any number of runs, concurrently too
+type Organizer struct {
+	config  *Config
+	mapping *ExtensionMapping
+	// templates maps slash separated category paths to their path template
+	templates       map[string]*pathTemplate
+	defaultTemplate *pathTemplate
+	// targetFolders holds the top-level folders organized files are laid out in, when the path
+	// templates make them known in advance
+	targetFolders map[string]bool
+	// renamer is nil when the config has no rename rules
+	renamer *renamer
+	// ignoreRules are the ignore rules of the config
+	ignoreRules []ignoreRule
+	// rules are the size and age rules of the config, in order
+	rules []fileRule
+}
+
+// New builds an Organizer from a parsed configuration, see LoadConfig and ParseConfig
+func New(config *Config) (*Organizer, error) {
+	mapping, err := buildExtensionMapping(config)
+	if err != nil {
+		return nil, fmt.Errorf("error building extension mapping: %w", err)
+	}
+
+	templates, defaultTemplate, err := parsePathTemplates(config)
+	if err != nil {
+		return nil, err
+	}
+	renamer, err := newRenamer(config.Rename)
+	if err != nil {
+		return nil, err
+	}
+	ignoreRules, err := parseIgnoreRules(config.Ignore)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := parseRules(config)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Organizer{
+		config:          config,
+		mapping:         mapping,
+		templates:       templates,
+		defaultTemplate: defaultTemplate,
+		targetFolders:   make(map[string]bool),
+		renamer:         renamer,
+		ignoreRules:     ignoreRules,
+		rules:           rules,
+	}
+
+	// Get the set of top-level folders from the mapping and the path templates
+	categories := make([]string, 0, len(mapping.ExtToPath)+len(mapping.Patterns)+len(mapping.MIMEToPath))
+	for _, folderPath := range mapping.ExtToPath {
+		categories = append(categories, folderPath)
+	}
+	for _, pattern := range mapping.Patterns {
+		categories = append(categories, pattern.Path)
+	}
+	for _, folderPath := range mapping.MIMEToPath {
+		categories = append(categories, folderPath)
+	}
+	for _, category := range categories {
+		if topFolder, ok := o.pathTemplateFor(category).topFolder(category); ok {
+			o.targetFolders[topFolder] = true
+		}
+	}
+	for _, rule := range rules {
+		if topFolder, ok := rule.template.topFolder(rule.category); ok {
+			o.targetFolders[topFolder] = true
+		}
+	}
+
+	return o, nil
+}
+
+// Config returns the configuration the Organizer was built from
+func (o *Organizer) Config() *Config {
+	return o.config
+}
+
+// Mapping returns the flattened extension to folder mapping, along with the extensions that
+// were claimed by more than one category
+func (o *Organizer) Mapping() *ExtensionMapping {
+	return o.mapping
+}
+
+// Plan resolves every move a run with opts would make, without touching the filesystem or
+// writing to the journal. The planned moves are returned in Stats.Moves
+func (o *Organizer) Plan(ctx context.Context, opts Options) (*Stats, error) {
+	opts.DryRun = true
+	opts.Journal = nil
+	opts.RecordFiles = true
+	return o.Execute(ctx, opts)
+}
+
+// ErrWalk is matched by the error of a run that could not read the source directory to the end,
+// see errors.Is
+var ErrWalk = errors.New("error walking directory")
+
+// Execute sorts the files of the source directory into category folders. When ctx is
+// cancelled the walk stops, every worker finishes (or rolls back) the file it is working on,
+// and the stats so far are returned together with the cancellation error. A directory that
+// cannot be read stops the walk the same way, with an error matching ErrWalk. Files that could
+// not be organized do not stop the run, the stats are returned with Stats.Err, which joins them
+func (o *Organizer) Execute(ctx context.Context, opts Options) (*Stats, error) {
+	run, err := o.newRun(opts)
+	if err != nil {
+		return nil, err
+	}
+	opts = run.opts
+	stats := run.stats
+
+	// Create a channel for jobs
+	jobs := make(chan fileJob, 100)
+
+	// Start worker goroutines
+	wg := run.startWorkers(ctx, jobs)
+
+	// Walk through the source directory, handing files to the workers as they are found
+	walkErr := run.walk(ctx, func(path string, d fs.DirEntry) error {
+		stats.IncrementTotal()
+		if job, ok := run.newJob(path, d.Name()); ok {
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		return nil
+	})
+
+	// Close the jobs channel to signal workers to exit
+	close(jobs)
+
+	// Wait for all workers to finish
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return stats, errors.Join(fmt.Errorf("organization interrupted: %w", ctx.Err()), stats.Err())
+	}
+	if walkErr != nil {
+		return stats, errors.Join(fmt.Errorf("%w: %w", ErrWalk, walkErr), stats.Err())
+	}
+
+	return stats, stats.Err()
+}
+
+// organizeRun holds the state shared by the directory walk and the workers of a run
+type organizeRun struct {
+	organizer     *Organizer
+	opts          Options
+	mapping       *ExtensionMapping
+	destRoot      string
+	targetFolders map[string]bool
+	stats         *Stats
+	reservations  *targetReservations
+	ignores       *ignoreMatcher
+	// openFiles is nil when the filesystem is not the one of the operating system
+	openFiles *openFiles
+}
+
+// newRun validates the options and prepares a run
+func (o *Organizer) newRun(opts Options) (*organizeRun, error) {
+	var err error
+
+	// Validate the conflict strategy and transfer mode before touching anything
+	if opts.OnConflict == "" {
+		opts.OnConflict = ConflictRename
+	} else if !slices.Contains(ConflictStrategies, opts.OnConflict) {
+		return nil, fmt.Errorf("unknown conflict strategy %q (expected one of: %s)", opts.OnConflict, strings.Join(ConflictStrategies, ", "))
+	}
+	if opts.Mode == "" {
+		opts.Mode = ModeMove
+	} else if !slices.Contains(Modes, opts.Mode) {
+		return nil, fmt.Errorf("unknown mode %q (expected one of: %s)", opts.Mode, strings.Join(Modes, ", "))
+	}
+	if opts.NumWorkers < 1 {
+		opts.NumWorkers = 1
+	}
+	if opts.ScanWorkers < 1 {
+		opts.ScanWorkers = 1
+	}
+	if opts.FS == nil {
+		opts.FS = OSFS{}
+	}
+	if opts.Stats == nil {
+		// Create stats to track progress
+		opts.Stats = &Stats{}
+	}
+	if opts.RecordFiles {
+		opts.Stats.listFiles()
+	}
+
+	run := &organizeRun{
+		organizer: o,
+		opts:      opts,
+		// Get the extension and name pattern to folder mapping
+		mapping:       o.mapping,
+		targetFolders: o.targetFolders,
+		stats:         opts.Stats,
+		// Track claimed target paths so workers never pick the same collision name
+		reservations: newTargetReservations(opts.FS, opts.Stats.AddWarning),
+	}
+
+	// Only the operating system knows which of its files are open
+	if _, ok := opts.FS.(OSFS); ok {
+		run.openFiles = &openFiles{}
+	}
+
+	if len(o.mapping.MIMEToPath) > 0 && !opts.DetectContent {
+		run.stats.AddWarning("the config maps content types, which are ignored unless content detection is enabled")
+	}
+
+	// Report extensions claimed by more than one category, and which one wins
+	for _, override := range o.mapping.Overrides {
+		run.stats.AddWarning(fmt.Sprintf("extension %s is mapped to both %s and %s, using %s",
+			override.Extension, override.PreviousPath, override.Path, override.Path))
+	}
+
+	// Resolve where organized files go, defaulting to organizing in place
+	if run.opts.SourcePath, err = filepath.Abs(opts.SourcePath); err != nil {
+		return nil, fmt.Errorf("error resolving source path: %w", err)
+	}
+	run.destRoot = run.opts.SourcePath
+	if opts.DestPath != "" {
+		if run.destRoot, err = filepath.Abs(opts.DestPath); err != nil {
+			return nil, fmt.Errorf("error resolving destination path: %w", err)
+		}
+	}
+
+	if run.ignores, err = o.newIgnoreMatcher(run.opts.FS, run.opts.SourcePath, opts.Exclude, run.stats); err != nil {
+		return nil, err
+	}
+
+	return run, nil
+}
+
+// startWorkers starts the worker goroutines, which exit once jobs is closed
+func (r *organizeRun) startWorkers(ctx context.Context, jobs <-chan fileJob) *sync.WaitGroup {
+	// Create a wait group to wait for all workers to finish
+	var wg sync.WaitGroup
+
+	for i := 0; i < r.opts.NumWorkers; i++ {
+		wg.Add(1)
+		go r.worker(ctx, jobs, &wg)
+	}
+
+	return &wg
+}
+
+// newIgnoreMatcher combines the ignore rules of the config with the exclude patterns of a run
+func (o *Organizer) newIgnoreMatcher(fsys FileSystem, root string, exclude []string, stats *Stats) (*ignoreMatcher, error) {
+	excludeRules, err := parseIgnoreRules(exclude)
+	if err != nil {
+		return nil, err
+	}
+	var warn func(string)
+	if stats != nil {
+		warn = stats.AddWarning
+	}
+	return newIgnoreMatcher(fsys, root, o.ignoreRules, excludeRules, warn), nil
+}
+
+// ignored reports whether a walk should leave an entry alone: the .organizerignore files
+// themselves, and anything the ignore rules match
+func (r *organizeRun) ignored(path string, isDir bool) bool {
+	if !isDir && filepath.Base(path) == ignoreFileName {
+		return true
+	}
+	return r.ignores.ignored(path, isDir)
+}
+
+// visit decides what a walk does with an entry: it returns true for files to organize, and
+// fs.SkipDir for directories that should not be entered
+func (r *organizeRun) visit(path string, d fs.DirEntry) (bool, error) {
+	if r.ignored(path, d.IsDir()) {
+		if d.IsDir() {
+			return false, fs.SkipDir
+		}
+		return false, nil
+	}
+	if !d.IsDir() {
+		return true, nil
+	}
+
+	// A destination inside the source holds organized files, never scan it
+	if r.isNestedDest(path) {
+		return false, fs.SkipDir
+	}
+	// If not recursive, only the files directly in the source directory are organized
+	if !r.opts.Recursive && path != r.opts.SourcePath {
+		return false, fs.SkipDir
+	}
+	return false, nil
+}
+
+// walk calls fn for every file a run organizes. With more than one scan worker directories are
+// read concurrently, and fn is called from several goroutines
+func (r *organizeRun) walk(ctx context.Context, fn func(path string, d fs.DirEntry) error) error {
+	visit := func(path string, d fs.DirEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if organize, err := r.visit(path, d); !organize {
+			return err
+		}
+		return fn(path, d)
+	}
+
+	if r.opts.ScanWorkers > 1 {
+		return parallelWalk(ctx, r.opts.FS, r.opts.SourcePath, r.opts.ScanWorkers, visit)
+	}
+	return r.opts.FS.WalkDir(r.opts.SourcePath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return visit(path, d)
+	})
+}
+
+// inUse reports whether a file may still be written to: modified within the minimum age, or held
+// open for writing by a process. It returns the matching skip reason
+func (r *organizeRun) inUse(path string) (string, bool) {
+	if r.opts.MinAge > 0 {
+		info, err := r.opts.FS.Stat(path)
+		if err != nil || time.Since(info.ModTime()) < r.opts.MinAge {
+			return SkipTooRecent, true
+		}
+	}
+	if r.openFiles != nil && r.openFiles.isOpen(path) {
+		return SkipOpen, true
+	}
+	return "", false
+}
+
+// isNestedDest reports whether dir is a destination root inside the source directory
+func (r *organizeRun) isNestedDest(dir string) bool {
+	return dir != r.opts.SourcePath && dir == r.destRoot
+}
+
+// newJob filters a file by its name alone, so the walk never waits on the disk. Downloads in
+// progress are skipped, and so are files no category matches unless their content may still
+// match one. Everything else is classified by the workers
+func (r *organizeRun) newJob(path, name string) (fileJob, bool) {
+	// Leave downloads in progress alone. Their temporary extension is usually unmapped, so they
+	// are recognized before the mapping is consulted
+	if isInProgress(name) {
+		r.stats.RecordSkip(path, SkipInProgress)
+		return fileJob{}, false
+	}
+
+	// Check if a name pattern or the extension says where this file goes. Files without an
+	// extension can only be matched by a pattern, or by their content when detection is enabled
+	folder, ext, matched := r.mapping.Match(name)
+	if !matched && !r.opts.DetectContent {
+		r.skipUnmatched(path, name)
+		return fileJob{}, false
+	}
+
+	return fileJob{sourcePath: path, name: name, folder: folder, ext: ext, matched: matched}, true
+}
+
+// skipUnmatched records a file no category matches as skipped
+func (r *organizeRun) skipUnmatched(path, name string) {
+	if filepath.Ext(name) == "" {
+		r.stats.RecordSkip(path, SkipNoExtension)
+	} else {
+		r.stats.RecordSkip(path, SkipUnmapped)
+	}
+}
+
+// classify decides where the file of a job goes, reading what it needs from the disk, and fills
+// in its target. Files that should not be organized are counted as processed and skipped
+func (r *organizeRun) classify(job fileJob) (fileJob, bool) {
+	path, name := job.sourcePath, job.name
+	folder, ext := job.folder, job.ext
+	if r.opts.DetectContent {
+		var exists bool
+		if folder, ext, exists = r.classifyContent(path, name, folder, ext, job.matched); !exists {
+			r.skipUnmatched(path, name)
+			return fileJob{}, false
+		}
+	}
+
+	// Leave files that are still being written alone
+	if reason, busy := r.inUse(path); busy {
+		r.stats.RecordSkip(path, reason)
+		return fileJob{}, false
+	}
+
+	// Lay the file out by the category's path template. The default adds the extension folder as
+	// additional level, files without one go in the category folder
+	template := r.organizer.pathTemplateFor(folder)
+	var info fs.FileInfo
+	if template.usesSize || len(r.organizer.rules) > 0 {
+		info, _ = r.opts.FS.Stat(path)
+	}
+
+	// Files matching a size or age rule go where the rule says instead
+	if rule := r.organizer.matchRule(folder, info, time.Now()); rule != nil {
+		template = rule.template
+	}
+
+	// Rename the file before its collisions are resolved, so the same file always gets the
+	// same name, and before laying it out, so {first_letter} follows the name it is filed under
+	filename := name
+	if r.organizer.renamer != nil {
+		filename = r.organizer.renamer.rename(r.opts.FS, path, name, ext, folder)
+	}
+
+	values := templateValues{category: folder, ext: ext, name: filename}
+	if template.usesDate {
+		values.date = fileDate(r.opts.FS, path)
+	}
+	if template.usesSize && info != nil {
+		values.size = info.Size()
+	}
+	targetDir := filepath.Join(r.destRoot, template.render(values))
+
+	// Skip files that are already where the template puts them, so the rename rules are not
+	// applied again to files organized by an earlier run
+	if filepath.Dir(path) == targetDir {
+		r.stats.RecordSkip(path, SkipOrganized)
+		return fileJob{}, false
+	}
+
+	job.targetDir = targetDir
+	job.filename = filename
+	job.category = filepath.ToSlash(folder)
+	return job, true
+}
+
+// worker classifies and processes file organization jobs. In dry-run mode the target path is
+// resolved and recorded exactly as it would be for a real move, but nothing is created or moved.
+// Once ctx is cancelled the remaining jobs are drained without being processed
+func (r *organizeRun) worker(ctx context.Context, jobs <-chan fileJob, wg *sync.WaitGroup) {
+	defer wg.Done()
+	stats, reservations, opts := r.stats, r.reservations, r.opts
+
+	for job := range jobs {
+		if ctx.Err() != nil {
+			continue
+		}
+
+		var ok bool
+		if job, ok = r.classify(job); !ok {
+			continue
+		}
+
+		// Ensure the target directory exists
+		if !opts.DryRun {
+			err := opts.FS.MkdirAll(job.targetDir, 0755)
+			if err != nil {
+				stats.RecordFailure(job.sourcePath, fmt.Errorf("create directory %s: %w", job.targetDir, err))
+				continue
+			}
+		}
+
+		move, skip := reservations.resolve(job, opts.OnConflict, opts.Mode)
+		if skip != "" {
+			// The target exists and the conflict strategy keeps the source where it is, or it is
+			// already a copy of (or link to) the source
+			stats.RecordSkip(job.sourcePath, skip)
+			continue
+		}
+		if move.Action == MoveActionDedupe && opts.Mode != ModeMove {
+			// An identical file is already there and the source must not be deleted
+			stats.RecordSkip(job.sourcePath, SkipDuplicate)
+			continue
+		}
+		move.Mode = opts.Mode
+
+		// Skip if source and target are the same file
+		if filepath.Clean(move.Source) == filepath.Clean(move.Target) {
+			stats.RecordSkip(job.sourcePath, SkipOrganized)
+			continue
+		}
+
+		if !opts.DryRun {
+			if err := applyMove(ctx, opts.FS, move); err != nil {
+				if ctx.Err() != nil {
+					// Interrupted mid-copy, the partial target was removed and the source kept
+					continue
+				}
+				stats.RecordFailure(job.sourcePath, err)
+				continue
+			}
+
+			if opts.Journal != nil {
+				if err := recordJournalMove(opts.FS, opts.Journal, move); err != nil {
+					stats.RecordError(job.sourcePath, err)
+				}
+			}
+		}
+
+		stats.RecordMove(move)
+		if opts.OnMove != nil {
+			opts.OnMove(move)
+		}
+		stats.IncrementProcessed()
+		if move.Action == MoveActionDedupe {
+			stats.IncrementDeduped()
+		} else {
+			stats.IncrementOrganized()
+		}
+	}
+}
+
+// applyMove performs a resolved move on disk, moving, copying or linking the file depending on
+// its mode. Duplicates are removed instead of moved. Cancelling ctx only interrupts copies
+func applyMove(ctx context.Context, fsys FileSystem, move FileMove) error {
+	if move.Action == MoveActionDedupe {
+		if err := fsys.Remove(move.Source); err != nil {
+			return fmt.Errorf("remove duplicate: %w", err)
+		}
+		return nil
+	}
+
+	switch move.Mode {
+	case ModeCopy:
+		if err := copyFile(ctx, fsys, move.Source, move.Target); err != nil {
+			return err
+		}
+		// Keep the original modification time on the copy
+		if info, err := fsys.Stat(move.Source); err == nil {
+			fsys.Chtimes(move.Target, info.ModTime(), info.ModTime())
+		}
+		return nil
+	case ModeHardlink, ModeSymlink:
+		// Links cannot replace an existing file, remove it first when overwriting
+		if move.Action == MoveActionOverwrite {
+			if err := fsys.Remove(move.Target); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("remove existing target: %w", err)
+			}
+		}
+		if move.Mode == ModeHardlink {
+			if err := fsys.Link(move.Source, move.Target); err != nil {
+				return fmt.Errorf("create hard link: %w", err)
+			}
+			return nil
+		}
+		if err := fsys.Symlink(absPath(move.Source), move.Target); err != nil {
+			return fmt.Errorf("create symbolic link: %w", err)
+		}
+		return nil
+	}
+
+	// Move the file using os.Rename which is more efficient
+	if err := fsys.Rename(move.Source, move.Target); err != nil {
+		// If rename fails (likely cross-device), fall back to copy+delete
+		return moveFileFallback(ctx, fsys, move.Source, move.Target)
+	}
+	return nil
+}
+
+// recordJournalMove writes a completed move to the journal along with the target's size and
+// modification time, so undo can tell whether the file was changed afterwards. Symbolic links
+// are described by the link itself, not the file it points at
+func recordJournalMove(fsys FileSystem, journal JournalWriter, move FileMove) error {
+	info, err := fsys.Lstat(move.Target)
+	if err != nil {
+		return fmt.Errorf("record move in journal: %w", err)
+	}
+	if err := journal.RecordMove(move, info.Size(), info.ModTime()); err != nil {
+		return fmt.Errorf("record move in journal: %w", err)
+	}
+	return nil
+}
+
+// moveFileFallback implements a copy+delete fallback when Rename fails (cross-device moves)
+func moveFileFallback(ctx context.Context, fsys FileSystem, src, dst string) error {
+	if err := copyFile(ctx, fsys, src, dst); err != nil {
+		return err
+	}
+
+	// Remove the source file
+	if err := fsys.Remove(src); err != nil {
+		return fmt.Errorf("remove source after copy: %w", err)
+	}
+
+	return nil
+}
+
+// copyFile copies the content of src to dst and flushes it to disk. The content is written to a
+// temporary file next to dst and renamed into place once complete, so a failed or cancelled copy
+// never leaves a partial dst behind (or truncates an existing one)
+func copyFile(ctx context.Context, fsys FileSystem, src, dst string) (err error) {
+	sourceFile, err := fsys.Open(src)
+	if err != nil {
+		return fmt.Errorf("open source file: %w", err)
+	}
+	defer sourceFile.Close()
+
+	destFile, err := fsys.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create destination file: %w", err)
+	}
+	defer func() {
+		destFile.Close()
+		if err != nil {
+			fsys.Remove(destFile.Name())
+		}
+	}()
+
+	_, err = io.Copy(destFile, &contextReader{ctx: ctx, r: sourceFile})
+	if err != nil {
+		return fmt.Errorf("copy file: %w", err)
+	}
+
+	// Ensure the file is flushed to disk
+	err = destFile.Sync()
+	if err != nil {
+		return fmt.Errorf("sync file: %w", err)
+	}
+
+	if err = destFile.Close(); err != nil {
+		return fmt.Errorf("close destination file: %w", err)
+	}
+
+	if err = fsys.Rename(destFile.Name(), dst); err != nil {
+		return fmt.Errorf("rename destination file: %w", err)
+	}
+
+	return nil
+}
+
+// contextReader stops reading once its context is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,399 @@
+package organizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestOrganizer builds an Organizer from a JSON configuration
+func newTestOrganizer(t *testing.T, data string) *Organizer {
+	t.Helper()
+	config, err := ParseConfig([]byte(data), ConfigFormatJSON)
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	org, err := New(config)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return org
+}
+
+// memFiles lists every regular file of a MemFS, sorted
+func memFiles(t *testing.T, memFS *MemFS) []string {
+	t.Helper()
+	var files []string
+	err := memFS.WalkDir("/", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestExecuteMemFS(t *testing.T) {
+	org := newTestOrganizer(t, `{"categories": {"images": [".jpg", ".png"], "documents": {"pdf": [".pdf"]}}}`)
+	memFS := newTestMemFS(t, map[string]string{
+		"/downloads/photo.jpg":      "jpg",
+		"/downloads/trip/photo.PNG": "png",
+		"/downloads/report.pdf":     "pdf",
+		"/downloads/notes":          "no extension",
+		"/downloads/archive.zip":    "zip",
+	})
+	opts := Options{SourcePath: "/downloads", NumWorkers: 2, Recursive: true, FS: memFS}
+
+	stats, err := org.Execute(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	want := []string{
+		"/downloads/archive.zip",
+		"/downloads/documents/pdf/pdf/report.pdf",
+		"/downloads/images/jpg/photo.jpg",
+		"/downloads/images/png/photo.PNG",
+		"/downloads/notes",
+	}
+	if got := memFiles(t, memFS); !slices.Equal(got, want) {
+		t.Errorf("files after the run = %v, want %v", got, want)
+	}
+	if stats.TotalFiles != 5 || stats.OrganizedFiles != 3 || stats.SkippedFiles != 2 {
+		t.Errorf("stats = %d total, %d organized, %d skipped, want 5, 3, 2", stats.TotalFiles, stats.OrganizedFiles, stats.SkippedFiles)
+	}
+	if stats.SkipsByReason[SkipNoExtension] != 1 || stats.SkipsByReason[SkipUnmapped] != 1 {
+		t.Errorf("SkipsByReason = %v", stats.SkipsByReason)
+	}
+
+	// A second run finds everything in place
+	stats, err = org.Execute(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("second Execute: %v", err)
+	}
+	if stats.OrganizedFiles != 0 || stats.SkipsByReason[SkipOrganized] != 3 {
+		t.Errorf("second run organized %d files and skipped %v, want nothing organized", stats.OrganizedFiles, stats.SkipsByReason)
+	}
+	if got := memFiles(t, memFS); !slices.Equal(got, want) {
+		t.Errorf("files after the second run = %v, want %v", got, want)
+	}
+}
+
+func TestExecuteMemFSTargetPrefix(t *testing.T) {
+	// Files whose path starts with their target folder are not inside it
+	org := newTestOrganizer(t, `{"categories": {"docs": [".pdf"]}, "path_template": "{category}"}`)
+	memFS := newTestMemFS(t, map[string]string{
+		"/downloads/docs-old.pdf":   "old",
+		"/downloads/docsreport.pdf": "report",
+		"/downloads/docs/kept.pdf":  "kept",
+	})
+
+	stats, err := org.Execute(context.Background(), Options{SourcePath: "/downloads", NumWorkers: 2, Recursive: true, FS: memFS})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	want := []string{"/downloads/docs/docs-old.pdf", "/downloads/docs/docsreport.pdf", "/downloads/docs/kept.pdf"}
+	if got := memFiles(t, memFS); !slices.Equal(got, want) {
+		t.Errorf("files after the run = %v, want %v", got, want)
+	}
+	if stats.OrganizedFiles != 2 || stats.SkipsByReason[SkipOrganized] != 1 {
+		t.Errorf("organized %d files and skipped %v, want 2 organized and kept.pdf skipped", stats.OrganizedFiles, stats.SkipsByReason)
+	}
+}
+
+func TestExecuteMemFSRenameFirstLetter(t *testing.T) {
+	org := newTestOrganizer(t, `{
+  "categories": {"docs": [".pdf"]},
+  "path_template": "{category}/{first_letter}",
+  "rename": {"template": "{date}_{name}{ext}"}
+}`)
+	memFS := newTestMemFS(t, map[string]string{"/downloads/apple.pdf": "apple"})
+	modified := time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local)
+	if err := memFS.Chtimes("/downloads/apple.pdf", modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{SourcePath: "/downloads", NumWorkers: 1, Recursive: true, FS: memFS}
+
+	// The first letter is taken from the new name, and a second run leaves the file alone
+	want := []string{"/downloads/docs/0-9/2026-10-17_apple.pdf"}
+	for run := 1; run <= 2; run++ {
+		if _, err := org.Execute(context.Background(), opts); err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+		if got := memFiles(t, memFS); !slices.Equal(got, want) {
+			t.Errorf("files after run %d = %v, want %v", run, got, want)
+		}
+	}
+}
+
+func TestExecuteMemFSInProgress(t *testing.T) {
+	org := newTestOrganizer(t, `{"categories": {"documents": [".pdf", ".docx"]}}`)
+	memFS := newTestMemFS(t, map[string]string{
+		"/downloads/report.pdf.crdownload": "unfinished",
+		"/downloads/~$letter.docx":         "lock",
+		"/downloads/video.part":            "unfinished",
+	})
+
+	stats, err := org.Execute(context.Background(), Options{SourcePath: "/downloads", NumWorkers: 1, FS: memFS})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if stats.SkipsByReason[SkipInProgress] != 3 || len(stats.SkipsByReason) != 1 {
+		t.Errorf("SkipsByReason = %v, want 3 in progress", stats.SkipsByReason)
+	}
+}
+
+// fileReadFS is a MemFS recording the files opened or stat'ed among those it was built with
+type fileReadFS struct {
+	*MemFS
+	files map[string]string
+	mu    sync.Mutex
+	read  []string
+}
+
+func (f *fileReadFS) record(name string) {
+	if _, exists := f.files[name]; exists {
+		f.mu.Lock()
+		f.read = append(f.read, name)
+		f.mu.Unlock()
+	}
+}
+
+func (f *fileReadFS) Open(name string) (fs.File, error) {
+	f.record(name)
+	return f.MemFS.Open(name)
+}
+
+func (f *fileReadFS) Stat(name string) (fs.FileInfo, error) {
+	f.record(name)
+	return f.MemFS.Stat(name)
+}
+
+func TestRunWalkReadsNamesOnly(t *testing.T) {
+	org := newTestOrganizer(t, `{"categories": {"images": [".png", "mime:image/*"]}, "path_templates": {"images": "{category}/{year}"}}`)
+	files := map[string]string{
+		"/downloads/photo.png":   "\x89PNG\r\n\x1a\nphoto",
+		"/downloads/screenshot":  "\x89PNG\r\n\x1a\nscreenshot",
+		"/downloads/notes.txt":   "notes",
+		"/downloads/video.part":  "unfinished",
+		"/downloads/sub/old.png": "\x89PNG\r\n\x1a\nold",
+	}
+	memFS := newTestMemFS(t, files)
+	modTime := time.Date(2020, 5, 1, 12, 0, 0, 0, time.Local)
+	for path := range files {
+		if err := memFS.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fsys := &fileReadFS{MemFS: memFS, files: files}
+	opts := Options{SourcePath: "/downloads", NumWorkers: 2, ScanWorkers: 2, Recursive: true, DetectContent: true, FS: fsys}
+
+	// Content detection and the date of the path template need the files, the walk only their names
+	run, err := org.newRun(opts)
+	if err != nil {
+		t.Fatalf("newRun: %v", err)
+	}
+	var queued []string
+	var mu sync.Mutex
+	err = run.walk(context.Background(), func(path string, d fs.DirEntry) error {
+		if job, ok := run.newJob(path, d.Name()); ok {
+			mu.Lock()
+			queued = append(queued, job.sourcePath)
+			mu.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk: %v", err)
+	}
+	sort.Strings(queued)
+	// Without a matching name, notes.txt may still be matched by its content
+	wantQueued := []string{"/downloads/notes.txt", "/downloads/photo.png", "/downloads/screenshot", "/downloads/sub/old.png"}
+	if !slices.Equal(queued, wantQueued) {
+		t.Errorf("queued %v, want %v", queued, wantQueued)
+	}
+	if len(fsys.read) != 0 {
+		t.Errorf("the walk read %v", fsys.read)
+	}
+
+	// The workers classify by content and date
+	stats, err := org.Execute(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	want := []string{
+		"/downloads/images/2020/old.png",
+		"/downloads/images/2020/photo.png",
+		"/downloads/images/2020/screenshot",
+		"/downloads/notes.txt",
+		"/downloads/video.part",
+	}
+	if got := memFiles(t, memFS); !slices.Equal(got, want) {
+		t.Errorf("files after the run = %v, want %v", got, want)
+	}
+	if stats.SkipsByReason[SkipInProgress] != 1 || stats.SkipsByReason[SkipUnmapped] != 1 || stats.OrganizedFiles != 3 {
+		t.Errorf("organized %d files and skipped %v", stats.OrganizedFiles, stats.SkipsByReason)
+	}
+}
+
+func TestStatsSnapshotDuringRun(t *testing.T) {
+	org := newTestOrganizer(t, `{"categories": {"images": [".jpg"]}}`)
+	files := make(map[string]string)
+	for i := range 50 {
+		files[fmt.Sprintf("/downloads/photo%d.jpg", i)] = "jpg"
+		files[fmt.Sprintf("/downloads/notes%d.txt", i)] = "txt"
+	}
+	memFS := newTestMemFS(t, files)
+	stats := &Stats{}
+
+	// Read the counters while the workers update them, run with -race
+	done := make(chan struct{})
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				if progress := stats.Snapshot(); progress.ProcessedFiles > progress.TotalFiles {
+					t.Errorf("snapshot processed %d of %d files", progress.ProcessedFiles, progress.TotalFiles)
+				}
+			}
+		}
+	}()
+
+	_, err := org.Execute(context.Background(), Options{SourcePath: "/downloads", NumWorkers: 4, FS: memFS, Stats: stats})
+	close(done)
+	<-watched
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	want := StatsSnapshot{TotalFiles: 100, ProcessedFiles: 100, OrganizedFiles: 50, SkippedFiles: 50}
+	if got := stats.Snapshot(); got != want {
+		t.Errorf("Snapshot = %+v, want %+v", got, want)
+	}
+}
+
+func TestExecuteMemFSRecordFiles(t *testing.T) {
+	org := newTestOrganizer(t, `{"categories": {"images": [".jpg"]}}`)
+
+	for _, record := range []bool{false, true} {
+		memFS := newTestMemFS(t, map[string]string{
+			"/downloads/photo.jpg": "jpg",
+			"/downloads/notes.txt": "txt",
+		})
+		stats, err := org.Execute(context.Background(), Options{SourcePath: "/downloads", NumWorkers: 1, FS: memFS, RecordFiles: record})
+		if err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+
+		// The counts are kept either way
+		if stats.OrganizedFiles != 1 || stats.SkipsByReason[SkipUnmapped] != 1 {
+			t.Errorf("RecordFiles %v: organized %d and skipped %v", record, stats.OrganizedFiles, stats.SkipsByReason)
+		}
+		if record {
+			wantSkips := []FileSkip{{Path: "/downloads/notes.txt", Reason: SkipUnmapped}}
+			if len(stats.Moves) != 1 || stats.Moves[0].Target != "/downloads/images/jpg/photo.jpg" || !slices.Equal(stats.Skips, wantSkips) {
+				t.Errorf("listed moves %+v and skips %+v", stats.Moves, stats.Skips)
+			}
+		} else if stats.Moves != nil || stats.Skips != nil {
+			t.Errorf("listed moves %+v and skips %+v without RecordFiles", stats.Moves, stats.Skips)
+		}
+	}
+}
+
+func TestPlanMemFS(t *testing.T) {
+	org := newTestOrganizer(t, `{"categories": {"images": [".jpg"]}}`)
+	memFS := newTestMemFS(t, map[string]string{
+		"/downloads/a/photo.jpg": "first",
+		"/downloads/b/photo.jpg": "second",
+	})
+
+	stats, err := org.Plan(context.Background(), Options{SourcePath: "/downloads", NumWorkers: 1, Recursive: true, FS: memFS})
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+
+	var targets []string
+	for _, move := range stats.Moves {
+		targets = append(targets, move.Target)
+	}
+	sort.Strings(targets)
+	want := []string{"/downloads/images/jpg/photo.jpg", "/downloads/images/jpg/photo_1.jpg"}
+	if !slices.Equal(targets, want) {
+		t.Errorf("planned targets = %v, want %v", targets, want)
+	}
+	if got := memFiles(t, memFS); !slices.Equal(got, []string{"/downloads/a/photo.jpg", "/downloads/b/photo.jpg"}) {
+		t.Errorf("Plan changed the filesystem: %v", got)
+	}
+}
+
+func TestExecuteMemFSFailures(t *testing.T) {
+	org := newTestOrganizer(t, `{"categories": {"images": [".jpg"]}}`)
+	// A file named like the category folder keeps it from being created
+	memFS := newTestMemFS(t, map[string]string{
+		"/downloads/images":    "in the way",
+		"/downloads/photo.jpg": "jpg",
+	})
+
+	stats, err := org.Execute(context.Background(), Options{SourcePath: "/downloads", NumWorkers: 1, FS: memFS})
+	var fileErr *FileError
+	if !errors.As(err, &fileErr) || fileErr.Path != "/downloads/photo.jpg" {
+		t.Fatalf("Execute error = %v, want a FileError for photo.jpg", err)
+	}
+	if stats == nil || stats.FailedFiles != 1 || len(stats.Errors) != 1 {
+		t.Fatalf("stats = %+v, want one failed file", stats)
+	}
+}
+
+// unreadableDirFS is a MemFS that cannot read one directory
+type unreadableDirFS struct {
+	*MemFS
+	dir string
+}
+
+func (f *unreadableDirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name == f.dir {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return f.MemFS.ReadDir(name)
+}
+
+func TestExecuteMemFSWalkError(t *testing.T) {
+	org := newTestOrganizer(t, `{"categories": {"images": [".jpg"]}}`)
+	memFS := newTestMemFS(t, map[string]string{
+		"/downloads/photo.jpg":            "jpg",
+		"/downloads/sub/locked/photo.jpg": "jpg",
+	})
+	fsys := &unreadableDirFS{MemFS: memFS, dir: "/downloads/sub/locked"}
+
+	// The files found before the walk failed are organized and counted. photo.jpg sorts before
+	// sub, so it is always found first
+	stats, err := org.Execute(context.Background(), Options{SourcePath: "/downloads", NumWorkers: 1, ScanWorkers: 2, Recursive: true, FS: fsys})
+	if !errors.Is(err, ErrWalk) || !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("Execute error = %v, want ErrWalk with the cause", err)
+	}
+	if stats == nil || stats.TotalFiles != 1 || stats.OrganizedFiles != 1 {
+		t.Fatalf("stats = %+v, want the file found before the failure organized", stats)
+	}
+	want := []string{"/downloads/images/jpg/photo.jpg", "/downloads/sub/locked/photo.jpg"}
+	if got := memFiles(t, memFS); !slices.Equal(got, want) {
+		t.Errorf("files after the run = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,181 @@
+package organizer
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// File name template variables, besides the date variables of path templates
+const (
+	// TemplateName is the file name without its extension
+	TemplateName = "name"
+	// TemplateDate is the date the file is filed under, as YYYY-MM-DD
+	TemplateDate = "date"
+)
+
+// renameVariables lists every variable a file name template may use. Unlike in path templates,
+// {ext} includes the leading dot
+var renameVariables = map[string]bool{
+	TemplateName:     true,
+	TemplateExt:      true,
+	TemplateCategory: true,
+	TemplateDate:     true,
+	TemplateYear:     true,
+	TemplateMonth:    true,
+	TemplateDay:      true,
+}
+
+// copySuffix matches the " (1)" suffix browsers and file managers add to repeated downloads
+var copySuffix = regexp.MustCompile(` \(\d+\)$`)
+
+// unsafeNameChars are the characters that cannot be used in a file name on Windows, macOS or Linux
+const unsafeNameChars = `<>:"/\|?*`
+
+// asciiReplacements transliterates letters that do not decompose into an ASCII letter and a mark
+var asciiReplacements = strings.NewReplacer(
+	"ß", "ss", "æ", "ae", "Æ", "AE", "œ", "oe", "Œ", "OE", "ø", "o", "Ø", "O",
+	"đ", "d", "Đ", "D", "ł", "l", "Ł", "L", "þ", "th", "Þ", "Th", "ð", "d", "Ð", "D",
+)
+
+// renamer applies the rename rules of a config to the files being organized
+type renamer struct {
+	rules RenameRules
+	// template is nil when the rules keep the original name
+	template []templateSegment
+	usesDate bool
+}
+
+// newRenamer parses the rename rules of a config, returning nil when there are none
+func newRenamer(rules *RenameRules) (*renamer, error) {
+	if rules == nil {
+		return nil, nil
+	}
+
+	r := &renamer{rules: *rules}
+	if rules.Template != "" {
+		if strings.ContainsAny(rules.Template, `/\`) {
+			return nil, fmt.Errorf("rename template %q must not contain path separators", rules.Template)
+		}
+		segments, err := parseTemplateSegments(rules.Template, renameVariables)
+		if err != nil {
+			return nil, fmt.Errorf("rename template %w", err)
+		}
+		r.template = segments
+		for _, segment := range segments {
+			switch segment.variable {
+			case TemplateDate, TemplateYear, TemplateMonth, TemplateDay:
+				r.usesDate = true
+			}
+		}
+	}
+	return r, nil
+}
+
+// rename returns the name a file is organized under. ext is the extension the file was matched
+// by, which may span several dots. The original name is kept when the rules leave nothing of it
+func (r *renamer) rename(fsys FileSystem, path, name, ext, category string) string {
+	base, fileExt := splitExt(name, ext)
+	if r.rules.StripCopySuffix {
+		base = copySuffix.ReplaceAllString(base, "")
+	}
+	if r.rules.LowercaseExt {
+		fileExt = strings.ToLower(fileExt)
+	}
+
+	renamed := base + fileExt
+	if r.template != nil {
+		values := templateValues{category: category, ext: fileExt, name: base}
+		if r.usesDate {
+			values.date = fileDate(fsys, path)
+		}
+
+		var b strings.Builder
+		for _, segment := range r.template {
+			switch segment.variable {
+			case "":
+				b.WriteString(segment.literal)
+			case TemplateName:
+				b.WriteString(values.name)
+			case TemplateExt:
+				b.WriteString(values.ext)
+			case TemplateCategory:
+				// Subcategories are joined with dashes, a file name cannot hold separators
+				b.WriteString(strings.ReplaceAll(filepath.ToSlash(values.category), "/", "-"))
+			case TemplateDate:
+				b.WriteString(values.date.Format("2006-01-02"))
+			case TemplateYear:
+				fmt.Fprintf(&b, "%04d", values.date.Year())
+			case TemplateMonth:
+				fmt.Fprintf(&b, "%02d", int(values.date.Month()))
+			case TemplateDay:
+				fmt.Fprintf(&b, "%02d", values.date.Day())
+			}
+		}
+		renamed = b.String()
+	}
+
+	if r.rules.Transliterate {
+		renamed = transliterate(renamed)
+	}
+	if r.rules.ReplaceSpaces != "" {
+		renamed = strings.Join(strings.Fields(renamed), r.rules.ReplaceSpaces)
+	}
+	if r.rules.Sanitize {
+		renamed = sanitizeName(renamed)
+	}
+
+	if strings.Trim(renamed, ". ") == "" {
+		return name
+	}
+	return renamed
+}
+
+// splitExt splits a file name into its base name and extension, using the extension it was
+// matched by when the name ends with it
+func splitExt(name, ext string) (string, string) {
+	if ext != "" && len(ext) < len(name) && strings.EqualFold(name[len(name)-len(ext):], ext) {
+		return name[:len(name)-len(ext)], name[len(name)-len(ext):]
+	}
+	fileExt := filepath.Ext(name)
+	if fileExt == name {
+		// Dot files such as .bashrc have no extension
+		return name, ""
+	}
+	return strings.TrimSuffix(name, fileExt), fileExt
+}
+
+// transliterate replaces non-ASCII letters with their closest ASCII equivalent, dropping accents,
+// and every other non-ASCII character with an underscore
+func transliterate(name string) string {
+	stripMarks := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	stripped, _, err := transform.String(stripMarks, asciiReplacements.Replace(name))
+	if err != nil {
+		stripped = name
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII {
+			return '_'
+		}
+		return r
+	}, stripped)
+}
+
+// sanitizeName replaces the characters that are unsafe in file names with underscores, and trims
+// the trailing dots and spaces Windows does not allow
+func sanitizeName(name string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(unsafeNameChars, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	return strings.TrimRight(sanitized, ". ")
+}
@@ -0,0 +1,84 @@
+package organizer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenamerRename(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    RenameRules
+		file     string
+		ext      string
+		category string
+		want     string
+	}{
+		{"no rules", RenameRules{}, "Photo (1).JPG", ".jpg", "images", "Photo (1).JPG"},
+		{"lowercase extension", RenameRules{LowercaseExt: true}, "Photo.JPG", ".jpg", "images", "Photo.jpg"},
+		{"lowercase multi-part extension", RenameRules{LowercaseExt: true}, "Backup.TAR.GZ", ".tar.gz", "archives", "Backup.tar.gz"},
+		{"strip copy suffix", RenameRules{StripCopySuffix: true}, "report (12).pdf", ".pdf", "documents", "report.pdf"},
+		{"strip only a numbered suffix", RenameRules{StripCopySuffix: true}, "report (draft).pdf", ".pdf", "documents", "report (draft).pdf"},
+		{"strip only at the end", RenameRules{StripCopySuffix: true}, "report (1) final.pdf", ".pdf", "documents", "report (1) final.pdf"},
+		{"replace spaces", RenameRules{ReplaceSpaces: "_"}, "my  holiday\tphoto.jpg", ".jpg", "images", "my_holiday_photo.jpg"},
+		{"replace surrounding spaces", RenameRules{ReplaceSpaces: "-"}, " photo .jpg", ".jpg", "images", "photo-.jpg"},
+
+		{"template", RenameRules{Template: "{date}_{name}{ext}"}, "photo.jpg", ".jpg", "images", "2020-05-01_photo.jpg"},
+		{"template date parts", RenameRules{Template: "{year}{month}{day}-{name}{ext}"}, "photo.jpg", ".jpg", "images", "20200501-photo.jpg"},
+		{"template category", RenameRules{Template: "{category}-{name}{ext}"}, "photo.jpg", ".jpg", "images/raw", "images-raw-photo.jpg"},
+		{"template multi-part extension", RenameRules{Template: "{name}_old{ext}"}, "backup.tar.gz", ".tar.gz", "archives", "backup_old.tar.gz"},
+		{"template extension from the name", RenameRules{Template: "{name}_old{ext}"}, "IMG_1.jpeg", "", "images", "IMG_1_old.jpeg"},
+		{"template dot file", RenameRules{Template: "{name}_old{ext}"}, ".bashrc", "", "config", ".bashrc_old"},
+		{"template after copy suffix", RenameRules{Template: "{name}{ext}", StripCopySuffix: true, LowercaseExt: true}, "photo (2).JPG", ".jpg", "images", "photo.jpg"},
+
+		{"sanitize", RenameRules{Sanitize: true}, `a<b>c:d"e|f?g*h` + "\x01.jpg", ".jpg", "images", "a_b_c_d_e_f_g_h_.jpg"},
+		{"sanitize trailing dots and spaces", RenameRules{Template: "{name}. .", Sanitize: true}, "photo.jpg", ".jpg", "images", "photo"},
+		{"sanitize template separators", RenameRules{Template: "{name}?{ext}", Sanitize: true}, "photo.jpg", ".jpg", "images", "photo_.jpg"},
+		{"transliterate", RenameRules{Transliterate: true}, "Café Ærø Straße.jpg", ".jpg", "images", "Cafe AEro Strasse.jpg"},
+		{"transliterate other scripts", RenameRules{Transliterate: true}, "写真.jpg", ".jpg", "images", "__.jpg"},
+		{"transliterate then replace spaces", RenameRules{Transliterate: true, ReplaceSpaces: "_"}, "Crème brûlée.jpg", ".jpg", "images", "Creme_brulee.jpg"},
+
+		{"nothing left keeps the name", RenameRules{Template: "{name}", StripCopySuffix: true}, " (1).jpg", ".jpg", "images", " (1).jpg"},
+		{"only dots left keeps the name", RenameRules{Template: "{name}.", Sanitize: true}, "...jpg", "", "images", "...jpg"},
+	}
+
+	modTime := time.Date(2020, 5, 1, 12, 0, 0, 0, time.Local)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/downloads/" + tt.file
+			memFS := newTestMemFS(t, map[string]string{path: "content"})
+			if err := memFS.Chtimes(path, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := newRenamer(&tt.rules)
+			if err != nil {
+				t.Fatalf("newRenamer: %v", err)
+			}
+			if got := r.rename(memFS, path, tt.file, tt.ext, tt.category); got != tt.want {
+				t.Errorf("rename(%q) = %q, want %q", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRenamer(t *testing.T) {
+	if r, err := newRenamer(nil); r != nil || err != nil {
+		t.Errorf("newRenamer(nil) = %v, %v, want no renamer", r, err)
+	}
+
+	r, err := newRenamer(&RenameRules{Template: "{name}{ext}"})
+	if err != nil || r.usesDate {
+		t.Errorf("newRenamer of a template without dates = %+v, %v", r, err)
+	}
+	r, err = newRenamer(&RenameRules{Template: "{month}-{name}{ext}"})
+	if err != nil || !r.usesDate {
+		t.Errorf("newRenamer of a template with the month = %+v, %v, want it to use the date", r, err)
+	}
+
+	for _, template := range []string{"{name}/{ext}", `{name}\{ext}`, "{bogus}{ext}", "{name", "{size_bucket}{ext}"} {
+		if _, err := newRenamer(&RenameRules{Template: template}); err == nil {
+			t.Errorf("newRenamer(%q) returned no error", template)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package organizer
+
+import (
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sizeUnits are the units sizes may be written in, as powers of 1024
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"tb", 1 << 40},
+	{"gb", 1 << 30},
+	{"mb", 1 << 20},
+	{"kb", 1 << 10},
+	{"b", 1},
+}
+
+// ageUnits are the units ages may be written in besides those of time.ParseDuration
+var ageUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+	"y": 365 * 24 * time.Hour,
+}
+
+// fileRule is a parsed Rule
+type fileRule struct {
+	// category is slash separated, empty for rules applying to every category
+	category         string
+	minSize, maxSize int64
+	minAge, maxAge   time.Duration
+	template         *pathTemplate
+}
+
+// parseRules parses the size and age rules of a config
+func parseRules(config *Config) ([]fileRule, error) {
+	rules := make([]fileRule, 0, len(config.Rules))
+	for i, rule := range config.Rules {
+		parsed, err := parseRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %d: %w", i+1, err)
+		}
+		rules = append(rules, parsed)
+	}
+	return rules, nil
+}
+
+func parseRule(rule Rule) (fileRule, error) {
+	parsed := fileRule{category: strings.Trim(strings.ReplaceAll(rule.Category, `\`, "/"), "/")}
+
+	var err error
+	if parsed.minSize, err = parseSize(rule.MinSize); err != nil {
+		return fileRule{}, err
+	}
+	if parsed.maxSize, err = parseSize(rule.MaxSize); err != nil {
+		return fileRule{}, err
+	}
+	if parsed.minAge, err = parseAge(rule.MinAge); err != nil {
+		return fileRule{}, err
+	}
+	if parsed.maxAge, err = parseAge(rule.MaxAge); err != nil {
+		return fileRule{}, err
+	}
+	if parsed.maxSize > 0 && parsed.minSize > parsed.maxSize {
+		return fileRule{}, fmt.Errorf("min_size %s is larger than max_size %s", rule.MinSize, rule.MaxSize)
+	}
+	if parsed.maxAge > 0 && parsed.minAge > parsed.maxAge {
+		return fileRule{}, fmt.Errorf("min_age %s is longer than max_age %s", rule.MinAge, rule.MaxAge)
+	}
+
+	if parsed.template, err = parsePathTemplate(rule.Path); err != nil {
+		return fileRule{}, err
+	}
+	return parsed, nil
+}
+
+// parseSize parses a size such as "2GB", "500 MB" or "1024". Units are powers of 1024, and an
+// empty size is 0
+func parseSize(size string) (int64, error) {
+	value := strings.ToLower(strings.TrimSpace(size))
+	if value == "" {
+		return 0, nil
+	}
+
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.bytes
+			break
+		}
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return int64(number * float64(multiplier)), nil
+}
+
+// parseAge parses an age such as "180d", "2w", "1y" or any time.ParseDuration value. An empty age
+// is 0
+func parseAge(age string) (time.Duration, error) {
+	value := strings.TrimSpace(age)
+	if value == "" {
+		return 0, nil
+	}
+
+	if unit, exists := ageUnits[value[len(value)-1:]]; exists {
+		number, err := strconv.ParseFloat(value[:len(value)-1], 64)
+		if err != nil || number < 0 {
+			return 0, fmt.Errorf("invalid age %q", age)
+		}
+		return time.Duration(number * float64(unit)), nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid age %q", age)
+	}
+	return duration, nil
+}
+
+// matches reports whether the rule applies to a file of a category
+func (r *fileRule) matches(category string, info fs.FileInfo, now time.Time) bool {
+	if r.category != "" {
+		category = strings.ReplaceAll(category, `\`, "/")
+		if category != r.category && !strings.HasPrefix(category, r.category+"/") {
+			return false
+		}
+	}
+
+	size, age := info.Size(), now.Sub(info.ModTime())
+	return (r.minSize == 0 || size > r.minSize) &&
+		(r.maxSize == 0 || size <= r.maxSize) &&
+		(r.minAge == 0 || age > r.minAge) &&
+		(r.maxAge == 0 || age <= r.maxAge)
+}
+
+// matchRule returns the first rule applying to a file of a category, or nil
+func (o *Organizer) matchRule(category string, info fs.FileInfo, now time.Time) *fileRule {
+	if info == nil {
+		return nil
+	}
+	for i := range o.rules {
+		if o.rules[i].matches(category, info, now) {
+			return &o.rules[i]
+		}
+	}
+	return nil
+}
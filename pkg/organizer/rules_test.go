@@ -0,0 +1,187 @@
+package organizer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size    string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"  ", 0, false},
+		{"1024", 1024, false},
+		{"10b", 10, false},
+		{"1KB", 1 << 10, false},
+		{"1kb", 1 << 10, false},
+		{"500 MB", 500 << 20, false},
+		{"2GB", 2 << 30, false},
+		{"1.5gb", 3 << 29, false},
+		{"1TB", 1 << 40, false},
+		{" 3 kb ", 3 << 10, false},
+		{"0", 0, false},
+		{"big", 0, true},
+		{"-1MB", 0, true},
+		{"MB", 0, true},
+		{"1PB", 0, true},
+		{"1 K", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSize(tt.size)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSize(%q) error = %v, wantErr %v", tt.size, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseSize(%q) = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		age     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"180d", 180 * day, false},
+		{"1.5d", 36 * time.Hour, false},
+		{"2w", 14 * day, false},
+		{"1y", 365 * day, false},
+		{" 7d ", 7 * day, false},
+		{"36h", 36 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"d", 0, true},
+		{"-1d", 0, true},
+		{"-1h", 0, true},
+		{"1 week", 0, true},
+		{"10", 0, true},
+		{"1D", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseAge(tt.age)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseAge(%q) error = %v, wantErr %v", tt.age, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseAge(%q) = %v, want %v", tt.age, got, tt.want)
+		}
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	rule, err := parseRule(Rule{Category: `\images\raw\`, MinSize: "1MB", MaxAge: "30d", Path: "recent/{year}"})
+	if err != nil {
+		t.Fatalf("parseRule: %v", err)
+	}
+	if rule.category != "images/raw" || rule.minSize != 1<<20 || rule.maxAge != 30*24*time.Hour || !rule.template.usesDate {
+		t.Errorf("parseRule = %+v", rule)
+	}
+
+	tests := []struct {
+		rule Rule
+		want string
+	}{
+		{Rule{MinSize: "big", Path: "x"}, `invalid size "big"`},
+		{Rule{MaxSize: "-2", Path: "x"}, `invalid size "-2"`},
+		{Rule{MinAge: "soon", Path: "x"}, `invalid age "soon"`},
+		{Rule{MaxAge: "1x", Path: "x"}, `invalid age "1x"`},
+		{Rule{MinSize: "2GB", MaxSize: "1GB", Path: "x"}, "min_size 2GB is larger than max_size 1GB"},
+		{Rule{MinAge: "2y", MaxAge: "1y", Path: "x"}, "min_age 2y is longer than max_age 1y"},
+		{Rule{MinSize: "1MB"}, "empty path template"},
+		{Rule{MinSize: "1MB", Path: "../x"}, "must not contain .."},
+		{Rule{MinSize: "1MB", Path: "{bogus}"}, "unknown variable"},
+	}
+	for _, tt := range tests {
+		if _, err := parseRule(tt.rule); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseRule(%+v) error = %v, want %q", tt.rule, err, tt.want)
+		}
+	}
+
+	// A maximum of 0 means there is none, so it is never below the minimum
+	if _, err := parseRule(Rule{MinSize: "1MB", MaxSize: "0", Path: "x"}); err != nil {
+		t.Errorf("parseRule with no maximum size: %v", err)
+	}
+}
+
+func TestMatchRule(t *testing.T) {
+	org := newTestOrganizer(t, `{
+  "categories": {"images": {"raw": [".cr2"], "web": [".jpg"]}, "videos": [".mp4"]},
+  "rules": [
+    {"category": "images/raw", "min_size": "1kb", "path": "raw-large"},
+    {"category": "images", "max_size": "10b", "path": "tiny"},
+    {"category": "videos", "min_age": "1y", "path": "old-videos"},
+    {"min_age": "30d", "max_age": "60d", "path": "last-month"}
+  ]
+}`)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	files := map[string]struct {
+		size int
+		age  time.Duration
+	}{
+		"/d/raw-large.cr2":  {2048, time.Hour},
+		"/d/raw-kb.cr2":     {1024, time.Hour},
+		"/d/raw-tiny.cr2":   {10, time.Hour},
+		"/d/web.jpg":        {500, time.Hour},
+		"/d/old.mp4":        {500, 400 * 24 * time.Hour},
+		"/d/new.mp4":        {500, 40 * 24 * time.Hour},
+		"/d/year.mp4":       {500, 365 * 24 * time.Hour},
+		"/d/last-month.jpg": {500, 45 * 24 * time.Hour},
+	}
+	contents := make(map[string]string, len(files))
+	for path, file := range files {
+		contents[path] = strings.Repeat("x", file.size)
+	}
+	memFS := newTestMemFS(t, contents)
+	for path, file := range files {
+		if err := memFS.Chtimes(path, now.Add(-file.age), now.Add(-file.age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path, category string
+		want           string
+	}{
+		// Sizes above the minimum and up to the maximum match
+		{"/d/raw-large.cr2", "images/raw", "raw-large"},
+		{"/d/raw-kb.cr2", "images/raw", ""},
+		{"/d/raw-tiny.cr2", "images/raw", "tiny"},
+		// Rules apply to subcategories, and categories may use either separator
+		{"/d/raw-tiny.cr2", `images\raw`, "tiny"},
+		{"/d/web.jpg", "images/web", ""},
+		{"/d/raw-large.cr2", "imagesx", ""},
+		// Ages above the minimum and up to the maximum match, the first matching rule wins
+		{"/d/old.mp4", "videos", "old-videos"},
+		{"/d/year.mp4", "videos", ""},
+		{"/d/new.mp4", "videos", "last-month"},
+		{"/d/last-month.jpg", "images/web", "last-month"},
+	}
+	for _, tt := range tests {
+		info, err := memFS.Stat(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		if rule := org.matchRule(tt.category, info, now); rule != nil {
+			got = rule.template.raw
+		}
+		if got != tt.want {
+			t.Errorf("matchRule(%s, %s) = %q, want %q", tt.category, tt.path, got, tt.want)
+		}
+	}
+
+	if rule := org.matchRule("images/raw", nil, now); rule != nil {
+		t.Errorf("matchRule without file info = %q, want none", rule.template.raw)
+	}
+}
@@ -0,0 +1,263 @@
+package organizer
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultPathTemplate is the layout used when the config sets no path template
+const defaultPathTemplate = "{category}/{ext}"
+
+// Path template variables
+const (
+	// TemplateCategory is the slash separated category path, such as documents/word
+	TemplateCategory = "category"
+	// TemplateExt is the extension without its leading dot, empty for files without one
+	TemplateExt = "ext"
+	// TemplateYear, TemplateMonth and TemplateDay come from the EXIF capture date of photos and
+	// from the modification time of every other file
+	TemplateYear  = "year"
+	TemplateMonth = "month"
+	TemplateDay   = "day"
+	// TemplateSizeBucket is small (under 1 MB), medium (under 100 MB), large (under 1 GB) or huge
+	TemplateSizeBucket = "size_bucket"
+	// TemplateFirstLetter is the uppercase first letter of the file name, 0-9 for digits and # otherwise
+	TemplateFirstLetter = "first_letter"
+)
+
+// templateVariables lists every variable a path template may use
+var templateVariables = map[string]bool{
+	TemplateCategory:    true,
+	TemplateExt:         true,
+	TemplateYear:        true,
+	TemplateMonth:       true,
+	TemplateDay:         true,
+	TemplateSizeBucket:  true,
+	TemplateFirstLetter: true,
+}
+
+// pathTemplate is a parsed target path template such as "{category}/{year}/{month}"
+type pathTemplate struct {
+	raw string
+	// segments alternate between literal text and variables
+	segments []templateSegment
+	usesDate bool
+	usesSize bool
+}
+
+type templateSegment struct {
+	literal  string
+	variable string
+}
+
+// templateValues holds the values a path template is rendered with
+type templateValues struct {
+	category string
+	ext      string
+	name     string
+	date     time.Time
+	size     int64
+}
+
+// parsePathTemplate parses a path template. Templates are slash separated paths relative to the
+// destination root, so they may not be absolute or contain ".."
+func parsePathTemplate(raw string) (*pathTemplate, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, fmt.Errorf("empty path template")
+	}
+	if path.IsAbs(raw) || filepath.IsAbs(raw) || strings.HasPrefix(raw, `\`) {
+		return nil, fmt.Errorf("path template %q must be relative", raw)
+	}
+	for _, part := range strings.Split(strings.ReplaceAll(raw, `\`, "/"), "/") {
+		if part == ".." {
+			return nil, fmt.Errorf("path template %q must not contain ..", raw)
+		}
+	}
+
+	segments, err := parseTemplateSegments(raw, templateVariables)
+	if err != nil {
+		return nil, fmt.Errorf("path template %w", err)
+	}
+
+	t := &pathTemplate{raw: raw, segments: segments}
+	for _, segment := range segments {
+		switch segment.variable {
+		case TemplateYear, TemplateMonth, TemplateDay:
+			t.usesDate = true
+		case TemplateSizeBucket:
+			t.usesSize = true
+		}
+	}
+	return t, nil
+}
+
+// parseTemplateSegments splits a template into literal text and the {variable} references it
+// makes, which must be listed in variables
+func parseTemplateSegments(raw string, variables map[string]bool) ([]templateSegment, error) {
+	var segments []templateSegment
+	rest := raw
+	for rest != "" {
+		start := strings.IndexAny(rest, "{}")
+		if start < 0 {
+			segments = append(segments, templateSegment{literal: rest})
+			break
+		}
+		if rest[start] == '}' {
+			return nil, fmt.Errorf("%q has an unmatched }", raw)
+		}
+		if start > 0 {
+			segments = append(segments, templateSegment{literal: rest[:start]})
+		}
+
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("%q has an unmatched {", raw)
+		}
+		name := rest[start+1 : start+end]
+		if !variables[name] {
+			return nil, fmt.Errorf("%q uses unknown variable {%s}", raw, name)
+		}
+		segments = append(segments, templateSegment{variable: name})
+		rest = rest[start+end+1:]
+	}
+	return segments, nil
+}
+
+// render returns the directory path the template describes, relative to the destination root.
+// Path elements left empty by a variable (such as {ext} for a file without an extension) are dropped
+func (t *pathTemplate) render(values templateValues) string {
+	var b strings.Builder
+	for _, segment := range t.segments {
+		switch segment.variable {
+		case "":
+			b.WriteString(segment.literal)
+		case TemplateCategory:
+			b.WriteString(filepath.ToSlash(values.category))
+		case TemplateExt:
+			b.WriteString(strings.TrimPrefix(values.ext, "."))
+		case TemplateYear:
+			fmt.Fprintf(&b, "%04d", values.date.Year())
+		case TemplateMonth:
+			fmt.Fprintf(&b, "%02d", int(values.date.Month()))
+		case TemplateDay:
+			fmt.Fprintf(&b, "%02d", values.date.Day())
+		case TemplateSizeBucket:
+			b.WriteString(sizeBucket(values.size))
+		case TemplateFirstLetter:
+			b.WriteString(firstLetter(values.name))
+		}
+	}
+
+	var parts []string
+	for _, part := range strings.Split(b.String(), "/") {
+		if part != "" && part != "." {
+			parts = append(parts, part)
+		}
+	}
+	return filepath.Join(parts...)
+}
+
+// topFolder returns the first path element of the directories the template lays out a category
+// in. It returns false when that element depends on the file, such as a template starting with {year}
+func (t *pathTemplate) topFolder(category string) (string, bool) {
+	var b strings.Builder
+	for _, segment := range t.segments {
+		text := segment.literal
+		switch segment.variable {
+		case "":
+		case TemplateCategory:
+			text = filepath.ToSlash(category)
+		default:
+			return "", false
+		}
+
+		if i := strings.IndexAny(text, `/\`); i >= 0 {
+			b.WriteString(text[:i])
+			break
+		}
+		b.WriteString(text)
+	}
+
+	top := b.String()
+	return top, top != "" && top != "."
+}
+
+// parsePathTemplates parses the path templates of a config, keyed by slash separated category
+// path, along with the default template
+func parsePathTemplates(config *Config) (map[string]*pathTemplate, *pathTemplate, error) {
+	templates := make(map[string]*pathTemplate, len(config.PathTemplates))
+	for category, raw := range config.PathTemplates {
+		t, err := parsePathTemplate(raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid path template for %s: %w", category, err)
+		}
+		templates[strings.Trim(filepath.ToSlash(category), "/")] = t
+	}
+
+	raw := config.PathTemplate
+	if raw == "" {
+		raw = defaultPathTemplate
+	}
+	defaultTemplate, err := parsePathTemplate(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid default path template: %w", err)
+	}
+
+	return templates, defaultTemplate, nil
+}
+
+// pathTemplateFor returns the template of a category, inherited from the closest parent category
+// that has one, or the default template
+func (o *Organizer) pathTemplateFor(category string) *pathTemplate {
+	for key := filepath.ToSlash(category); key != "." && key != ""; key = path.Dir(key) {
+		if t, exists := o.templates[key]; exists {
+			return t
+		}
+	}
+	return o.defaultTemplate
+}
+
+// sizeBucket names the size range of a file
+func sizeBucket(size int64) string {
+	switch {
+	case size < 1<<20:
+		return "small"
+	case size < 100<<20:
+		return "medium"
+	case size < 1<<30:
+		return "large"
+	default:
+		return "huge"
+	}
+}
+
+// firstLetter returns the uppercase first letter of a file name, 0-9 for names starting with a
+// digit and # for anything else
+func firstLetter(name string) string {
+	r, _ := utf8.DecodeRuneInString(name)
+	switch {
+	case unicode.IsLetter(r):
+		return string(unicode.ToUpper(r))
+	case unicode.IsDigit(r):
+		return "0-9"
+	default:
+		return "#"
+	}
+}
+
+// fileDate returns the date a file is filed under: the EXIF capture date of JPEG, TIFF and HEIC
+// photos, or the modification time
+func fileDate(fsys FileSystem, path string) time.Time {
+	if taken, ok := exifDateTaken(fsys, path); ok {
+		return taken
+	}
+	if info, err := fsys.Stat(path); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
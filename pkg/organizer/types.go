@@ -0,0 +1,527 @@
+package organizer
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Options configures a single organize or watch run
+type Options struct {
+	// SourcePath is the directory whose files are organized
+	SourcePath string
+	// DestPath is the root organized files are moved into, empty organizes SourcePath in place
+	DestPath   string
+	NumWorkers int
+	// ScanWorkers is how many directories are read at the same time, defaults to 1. More help on
+	// network filesystems, where reading directories is slow
+	ScanWorkers int
+	Recursive   bool
+	// DryRun plans every move without touching the filesystem
+	DryRun bool
+	// Journal records every move so the run can be undone, nil disables journaling
+	Journal JournalWriter
+	// OnConflict is the strategy used when the target file already exists, defaults to ConflictRename
+	OnConflict string
+	// Mode is how files are placed in the target directory, defaults to ModeMove
+	Mode string
+	// DetectContent classifies files by their content when their name does not match any rule,
+	// or when their content contradicts their extension
+	DetectContent bool
+	// MinAge leaves files modified more recently than this alone, as they may still be written to
+	MinAge time.Duration
+	// Exclude holds gitignore-style patterns, relative to SourcePath, of files and directories to
+	// leave alone. They take precedence over the ignore rules of the config and .organizerignore files
+	Exclude []string
+	// RecordFiles lists every move and every skipped file in Stats.Moves and Stats.Skips. They
+	// are left empty otherwise, so memory does not grow with the number of files. Plan always
+	// lists them
+	RecordFiles bool
+	// OnMove is called from the worker goroutines after each file is organized, may be nil
+	OnMove func(move FileMove)
+	// OnError is called with the problems that do not stop a watch, such as a directory that could
+	// not be watched. When nil they are recorded as warnings in the stats
+	OnError func(err error)
+	// FS is the filesystem the files are organized on, defaults to OSFS
+	FS FileSystem
+	// Stats receives the counters while the run is in progress, so they can be displayed as it
+	// goes through Snapshot. A new Stats is used when nil
+	Stats *Stats
+}
+
+// WatchOptions configures how a watch run picks up new files
+type WatchOptions struct {
+	// Settle is how long a file must go without being created or written to before it is organized
+	Settle time.Duration
+	// ScanExisting organizes the files already in the source directory when the watch starts
+	ScanExisting bool
+}
+
+// Transfer modes, deciding how a file is placed in its target directory
+const (
+	// ModeMove moves the file, leaving nothing at the source
+	ModeMove = "move"
+	// ModeCopy copies the file, leaving the source untouched
+	ModeCopy = "copy"
+	// ModeHardlink creates a hard link to the source, which must be on the same volume
+	ModeHardlink = "hardlink"
+	// ModeSymlink creates a symbolic link pointing at the source
+	ModeSymlink = "symlink"
+)
+
+// Modes lists every supported transfer mode
+var Modes = []string{ModeMove, ModeCopy, ModeHardlink, ModeSymlink}
+
+// Conflict resolution strategies, used when a file with the same name already exists in the target directory
+const (
+	// ConflictRename appends a number to the filename (name_1.ext, name_2.ext, ...)
+	ConflictRename = "rename"
+	// ConflictSkip leaves the source file where it is
+	ConflictSkip = "skip"
+	// ConflictOverwrite replaces the existing file
+	ConflictOverwrite = "overwrite"
+	// ConflictOverwriteIfNewer replaces the existing file when the source was modified more recently
+	ConflictOverwriteIfNewer = "overwrite-if-newer"
+	// ConflictOverwriteIfLarger replaces the existing file when the source is larger
+	ConflictOverwriteIfLarger = "overwrite-if-larger"
+	// ConflictDedupe deletes the source when an identical file already exists, and renames otherwise
+	ConflictDedupe = "dedupe"
+)
+
+// ConflictStrategies lists every supported conflict resolution strategy
+var ConflictStrategies = []string{
+	ConflictRename,
+	ConflictSkip,
+	ConflictOverwrite,
+	ConflictOverwriteIfNewer,
+	ConflictOverwriteIfLarger,
+	ConflictDedupe,
+}
+
+// fileJob is a file the walk handed to the workers. The walk fills in what the name of the file
+// matched, the worker classifying it fills in its target
+type fileJob struct {
+	sourcePath string
+	name       string
+	// folder and ext are what the name matched, matched is false when it matched nothing
+	folder, ext string
+	matched     bool
+
+	targetDir string
+	filename  string
+	// category is the slash separated category path the file was matched to
+	category string
+}
+
+// File move actions
+const (
+	// MoveActionMove moves the file to a free target path
+	MoveActionMove = "move"
+	// MoveActionOverwrite moves the file, replacing the existing target
+	MoveActionOverwrite = "overwrite"
+	// MoveActionDedupe deletes the file because the target holds identical content
+	MoveActionDedupe = "dedupe"
+)
+
+// FileMove records a file being moved (or copied or linked) from its source to its final target path
+type FileMove struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	Action   string `json:"action"`
+	Mode     string `json:"mode,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+// Skip reasons, explaining why a file was left where it is
+const (
+	// SkipNoExtension is used for files without an extension that no category matches
+	SkipNoExtension = "no-extension"
+	// SkipUnmapped is used for files whose extension no category lists
+	SkipUnmapped = "unmapped-extension"
+	// SkipOrganized is used for files already in their target directory
+	SkipOrganized = "already-organized"
+	// SkipInProgress is used for downloads and temporary files that are still being written
+	SkipInProgress = "in-progress"
+	// SkipTooRecent is used for files modified within Options.MinAge
+	SkipTooRecent = "too-recent"
+	// SkipOpen is used for files a process holds open for writing
+	SkipOpen = "open-for-writing"
+	// SkipConflict is used when the target exists and the conflict strategy keeps the source
+	SkipConflict = "conflict"
+	// SkipDuplicate is used when an identical file exists at the target, but the mode does not
+	// allow deleting the source
+	SkipDuplicate = "duplicate"
+)
+
+// SkipReasons lists every skip reason
+var SkipReasons = []string{
+	SkipNoExtension,
+	SkipUnmapped,
+	SkipOrganized,
+	SkipInProgress,
+	SkipTooRecent,
+	SkipOpen,
+	SkipConflict,
+	SkipDuplicate,
+}
+
+// FileSkip records a file that was left where it is
+type FileSkip struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// FileError records a file that could not be organized
+type FileError struct {
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// Stats tracks the progress of the file organization
+type Stats struct {
+	// TotalFiles grows while the source directory is scanned, and is exact once the run returns
+	TotalFiles     int
+	ProcessedFiles int
+	OrganizedFiles int
+	SkippedFiles   int
+	DedupedFiles   int
+	// FailedFiles counts the files that could not be organized, which are listed in Errors
+	FailedFiles int
+	// Moves holds every move performed (or planned in dry-run mode), when Options.RecordFiles is
+	// set
+	Moves []FileMove
+	// Skips holds every file that was left where it is, with the reason, when Options.RecordFiles
+	// is set
+	Skips []FileSkip
+	// SkipsByReason counts the skipped files by reason
+	SkipsByReason map[string]int
+	// Errors holds the problems met while organizing files. A file whose move succeeded but could
+	// not be journaled is listed here too
+	Errors []*FileError
+	// Warnings holds non-fatal problems, such as extensions claimed by more than one category
+	Warnings []string
+	// recordFiles fills Moves and Skips
+	recordFiles bool
+	mu          sync.Mutex
+}
+
+// StatsSnapshot is a consistent copy of the counters of Stats
+type StatsSnapshot struct {
+	TotalFiles     int
+	ProcessedFiles int
+	OrganizedFiles int
+	SkippedFiles   int
+	DedupedFiles   int
+	FailedFiles    int
+}
+
+// Snapshot copies the counters under the lock, so they can be read while a run updates them
+func (s *Stats) Snapshot() StatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return StatsSnapshot{
+		TotalFiles:     s.TotalFiles,
+		ProcessedFiles: s.ProcessedFiles,
+		OrganizedFiles: s.OrganizedFiles,
+		SkippedFiles:   s.SkippedFiles,
+		DedupedFiles:   s.DedupedFiles,
+		FailedFiles:    s.FailedFiles,
+	}
+}
+
+func (s *Stats) IncrementTotal() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.TotalFiles++
+}
+
+// listFiles makes the stats keep every move and skipped file, not only their counts
+func (s *Stats) listFiles() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordFiles = true
+}
+
+func (s *Stats) IncrementProcessed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ProcessedFiles++
+}
+
+func (s *Stats) IncrementOrganized() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.OrganizedFiles++
+}
+
+func (s *Stats) IncrementSkipped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.SkippedFiles++
+}
+
+// RecordSkip counts a file as processed and skipped for the given reason
+func (s *Stats) RecordSkip(path, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ProcessedFiles++
+	s.SkippedFiles++
+	if s.recordFiles {
+		s.Skips = append(s.Skips, FileSkip{Path: path, Reason: reason})
+	}
+	if s.SkipsByReason == nil {
+		s.SkipsByReason = make(map[string]int)
+	}
+	s.SkipsByReason[reason]++
+}
+
+// RecordFailure counts a file as processed and failed
+func (s *Stats) RecordFailure(path string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ProcessedFiles++
+	s.FailedFiles++
+	s.Errors = append(s.Errors, &FileError{Path: path, Err: err})
+}
+
+// RecordError records a problem with a file without counting it, such as a move that could not
+// be journaled
+func (s *Stats) RecordError(path string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Errors = append(s.Errors, &FileError{Path: path, Err: err})
+}
+
+// Err joins the errors recorded for files, so that errors.As finds each *FileError. It returns
+// nil when there are none
+func (s *Stats) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	errs := make([]error, len(s.Errors))
+	for i, fileErr := range s.Errors {
+		errs[i] = fileErr
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Stats) IncrementDeduped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.DedupedFiles++
+}
+
+func (s *Stats) AddWarning(warning string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Warnings = append(s.Warnings, warning)
+}
+
+// RecordMove lists a move when the run records files
+func (s *Stats) RecordMove(move FileMove) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.recordFiles {
+		s.Moves = append(s.Moves, move)
+	}
+}
+
+// JournalWriter records the changes made during an organize run so it can be undone
+type JournalWriter interface {
+	RecordMove(move FileMove, size int64, modTime time.Time) error
+	RecordRemovedDir(path string) error
+}
+
+// Journal entry actions
+const (
+	JournalActionStart     = "start"
+	JournalActionMove      = "move"
+	JournalActionDedupe    = "dedupe"
+	JournalActionRemoveDir = "rmdir"
+	JournalActionUndo      = "undo"
+	// JournalActionUndoEntry records a single move that was undone, so an undo that did not
+	// complete can be retried
+	JournalActionUndoEntry = "undo-entry"
+)
+
+// JournalEntry is a single line of a run journal
+type JournalEntry struct {
+	Action string `json:"action"`
+	// Path is the organized directory for start entries and the removed directory for rmdir entries
+	Path string `json:"path,omitempty"`
+	// Source and Target are the original and final path of a file, for start entries Target is the destination root
+	Source string `json:"source,omitempty"`
+	Target string `json:"target,omitempty"`
+	// Mode is how the file was placed at the target, empty for moves
+	Mode string `json:"mode,omitempty"`
+	// Replaced is set when the move overwrote an existing target
+	Replaced bool `json:"replaced,omitempty"`
+	// Size and ModTime describe the target file right after the move
+	Size    int64     `json:"size,omitempty"`
+	ModTime time.Time `json:"mod_time,omitzero"`
+	Time    time.Time `json:"time"`
+}
+
+// RunSummary describes a journaled organize run
+type RunSummary struct {
+	RunID   string
+	Path    string
+	Dest    string
+	Started time.Time
+	Moves   int
+	Undone  bool
+	// PartlyUndone is set when an undo put some of the files back but could not complete
+	PartlyUndone bool
+}
+
+// UndoResult reports the outcome of undoing a run
+type UndoResult struct {
+	RunID         string
+	RestoredFiles int
+	// RemovedFiles counts copies and links created by the run that were removed
+	RemovedFiles  int
+	RecreatedDirs int
+	// Changed holds targets whose size or modification time differ from the journal
+	Changed []string
+	// Missing holds targets that no longer exist
+	Missing []string
+	// Conflicts holds original paths that are occupied by another file
+	Conflicts []string
+	// Failed holds files that could not be moved back
+	Failed []string
+	// Replaced holds targets that overwrote an existing file, whose previous content cannot be restored
+	Replaced []string
+	// Errors holds problems that are not tied to a single file, such as a directory that could
+	// not be recreated
+	Errors []error
+}
+
+// Complete reports whether every change of the run was undone
+func (r *UndoResult) Complete() bool {
+	return len(r.Changed) == 0 && len(r.Missing) == 0 && len(r.Conflicts) == 0 && len(r.Failed) == 0 && len(r.Errors) == 0
+}
+
+// Config issue severities
+const (
+	IssueError   = "error"
+	IssueWarning = "warning"
+)
+
+// ConfigIssue is a problem found while validating a configuration file
+type ConfigIssue struct {
+	Severity string
+	// Category is the slash separated path of the category the issue belongs to
+	Category string
+	Message  string
+}
+
+// Config represents the structure of the configuration file
+type Config struct {
+	// Map of folder names to lists of extensions or nested categories
+	Categories map[string]json.RawMessage `json:"categories"`
+	// PathTemplate is the layout of the target directories of categories without their own path
+	// template, "{category}/{ext}" when empty
+	PathTemplate string `json:"path_template,omitempty"`
+	// PathTemplates maps slash separated category paths to the layout of their target directories,
+	// such as "{category}/{year}/{month}". Subcategories inherit the template of their parent
+	PathTemplates map[string]string `json:"path_templates,omitempty"`
+	// Rules send files of a given size or age to their own directory. The first matching rule wins
+	Rules []Rule `json:"rules,omitempty"`
+	// Ignore holds gitignore-style patterns of files and directories to leave alone, relative to
+	// the organized directory
+	Ignore []string `json:"ignore,omitempty"`
+	// Rename holds the rules organized files are renamed by, nil keeps their names
+	Rename *RenameRules `json:"rename,omitempty"`
+	// CategoryOrder lists the top-level category names in the order they are declared
+	CategoryOrder []string `json:"-"`
+}
+
+// Rule sends the files matching every one of its conditions to its own directory, instead of the
+// one the path template of their category gives. Sizes are written like "2GB" and ages like "180d"
+type Rule struct {
+	// Category limits the rule to a category and its subcategories, empty applies it to every
+	// categorized file
+	Category string `json:"category,omitempty"`
+	// MinSize and MaxSize match files larger than MinSize and at most MaxSize
+	MinSize string `json:"min_size,omitempty"`
+	MaxSize string `json:"max_size,omitempty"`
+	// MinAge and MaxAge match files last modified longer ago than MinAge and at most MaxAge ago
+	MinAge string `json:"min_age,omitempty"`
+	MaxAge string `json:"max_age,omitempty"`
+	// Path is the path template of the target directory, such as "archive/{year}"
+	Path string `json:"path"`
+}
+
+// RenameRules describes how organized files are renamed. They are applied before collisions with
+// existing files are resolved
+type RenameRules struct {
+	// Template is the new file name, such as "{date}_{name}{ext}"
+	Template string `json:"template,omitempty"`
+	// LowercaseExt lowercases the extension
+	LowercaseExt bool `json:"lowercase_ext,omitempty"`
+	// ReplaceSpaces replaces every run of whitespace with the given string, such as "_"
+	ReplaceSpaces string `json:"replace_spaces,omitempty"`
+	// Sanitize replaces the characters that are not allowed in file names on Windows, macOS or
+	// Linux with underscores
+	Sanitize bool `json:"sanitize,omitempty"`
+	// StripCopySuffix removes the " (1)" suffix browsers add to repeated downloads
+	StripCopySuffix bool `json:"strip_copy_suffix,omitempty"`
+	// Transliterate replaces non-ASCII characters with their closest ASCII equivalent
+	Transliterate bool `json:"transliterate,omitempty"`
+}
+
+// Category represents either a list of extensions or nested subcategories
+type Category struct {
+	// Extensions holds a list of file extensions if this is a leaf category
+	Extensions []string
+	// Subcategories holds nested categories if this is not a leaf category
+	Subcategories map[string]*Category
+	// SubcategoryOrder lists the subcategory names in the order they are declared
+	SubcategoryOrder []string
+}
+
+// ExtensionMapping maps file extensions and name patterns to their target directories
+type ExtensionMapping struct {
+	// Map of extension to directory path (relative to source)
+	ExtToPath map[string]string
+	// Patterns holds the glob and regex name patterns in declaration order
+	Patterns []NamePattern
+	// MIMEToPath maps content types ("image/png") and wildcards ("image/*") to directory paths,
+	// used when content detection is enabled
+	MIMEToPath map[string]string
+	// Overrides lists every extension that was claimed by more than one category
+	Overrides []ExtensionOverride
+}
+
+// Name pattern kinds, written as a "glob:" or "regex:" prefix in a category's extension list
+const (
+	// PatternGlob matches the file name against a shell pattern, ignoring case
+	PatternGlob = "glob"
+	// PatternRegex matches the file name against a regular expression
+	PatternRegex = "regex"
+)
+
+// NamePattern routes the files whose name matches a glob or regular expression to a directory
+type NamePattern struct {
+	Kind    string
+	Pattern string
+	// Path is the directory path (relative to source) matching files are organized into
+	Path   string
+	regexp *regexp.Regexp
+}
+
+// ExtensionOverride records a later category taking over an extension from an earlier one
+type ExtensionOverride struct {
+	Extension    string
+	PreviousPath string
+	Path         string
+}
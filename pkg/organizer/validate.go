@@ -0,0 +1,303 @@
+package organizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// reservedFolderNames are names Windows does not allow for files or directories
+var reservedFolderNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// configValidator walks the raw category data and collects the issues it finds
+type configValidator struct {
+	issues []ConfigIssue
+	// extensions maps each normalized extension (or name pattern) to the category it is currently mapped to
+	extensions map[string]string
+	// categories holds the slash separated path of every category
+	categories map[string]bool
+}
+
+// ValidateConfig loads a configuration file and validates it, see Validate. An error is only
+// returned when the file cannot be loaded
+func ValidateConfig(configPath, format string) ([]ConfigIssue, error) {
+	config, err := LoadConfig(configPath, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return Validate(config), nil
+}
+
+// Validate reports duplicate extensions, empty categories, malformed entries, invalid folder
+// names and invalid path templates. Categories are checked in the same order
+// buildExtensionMapping applies them, so duplicates name the category that takes precedence
+func Validate(config *Config) []ConfigIssue {
+	v := &configValidator{extensions: make(map[string]string), categories: make(map[string]bool)}
+
+	if len(config.Categories) == 0 {
+		v.add(IssueError, "", "config does not define any categories")
+	}
+
+	for _, name := range categoryNames(config) {
+		v.checkFolderName("", name)
+		v.checkCategory(name, config.Categories[name])
+	}
+
+	v.checkPathTemplates(config.PathTemplates)
+	v.checkRules(config.Rules)
+	if config.PathTemplate != "" {
+		if _, err := parsePathTemplate(config.PathTemplate); err != nil {
+			v.add(IssueError, "path_template", "%v", err)
+		}
+	}
+	for _, line := range config.Ignore {
+		if _, _, err := parseIgnoreRule(line); err != nil {
+			v.add(IssueError, "ignore", "%v", err)
+		}
+	}
+	if _, err := newRenamer(config.Rename); err != nil {
+		v.add(IssueError, "rename", "%v", err)
+	}
+
+	return v.issues
+}
+
+func (v *configValidator) add(severity, category, format string, args ...any) {
+	v.issues = append(v.issues, ConfigIssue{
+		Severity: severity,
+		Category: category,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// checkCategory mirrors parseCategory, reporting everything it would silently ignore
+func (v *configValidator) checkCategory(categoryPath string, data json.RawMessage) {
+	v.categories[categoryPath] = true
+
+	// Simple extension list
+	var extensions []string
+	if err := json.Unmarshal(data, &extensions); err == nil {
+		if len(extensions) == 0 {
+			v.add(IssueWarning, categoryPath, "category is empty")
+		}
+		v.checkExtensions(categoryPath, extensions)
+		return
+	}
+
+	// Array mixing extension lists and subcategory objects
+	var objArray []json.RawMessage
+	if err := json.Unmarshal(data, &objArray); err == nil {
+		if len(objArray) == 0 {
+			v.add(IssueWarning, categoryPath, "category is empty")
+		}
+
+		// A category's own extensions are applied before its subcategories
+		var subcategoryItems []json.RawMessage
+		for i, item := range objArray {
+			var strArray []string
+			if err := json.Unmarshal(item, &strArray); err == nil {
+				if len(strArray) == 0 {
+					v.add(IssueWarning, categoryPath, "entry %d is an empty extension list", i+1)
+				}
+				v.checkExtensions(categoryPath, strArray)
+				continue
+			}
+
+			var objMap map[string]json.RawMessage
+			if err := json.Unmarshal(item, &objMap); err == nil && objMap != nil {
+				if len(objMap) == 0 {
+					v.add(IssueWarning, categoryPath, "entry %d is an empty subcategory object", i+1)
+				}
+				subcategoryItems = append(subcategoryItems, item)
+				continue
+			}
+
+			v.add(IssueError, categoryPath, "entry %d is ignored, expected an extension list or subcategory object but got %s", i+1, compactJSON(item))
+		}
+
+		for _, item := range subcategoryItems {
+			v.checkSubcategories(categoryPath, item)
+		}
+		return
+	}
+
+	// Object of subcategories
+	var objMap map[string]json.RawMessage
+	if err := json.Unmarshal(data, &objMap); err == nil && objMap != nil {
+		if len(objMap) == 0 {
+			v.add(IssueWarning, categoryPath, "category is empty")
+		}
+		v.checkSubcategories(categoryPath, data)
+		return
+	}
+
+	v.add(IssueError, categoryPath, "unable to parse category, expected an extension list, array or object but got %s", compactJSON(data))
+}
+
+func (v *configValidator) checkSubcategories(parentPath string, data json.RawMessage) {
+	var subcats map[string]json.RawMessage
+	if err := json.Unmarshal(data, &subcats); err != nil {
+		return
+	}
+	names, err := objectKeys(data)
+	if err != nil {
+		return
+	}
+
+	for _, name := range names {
+		v.checkFolderName(parentPath, name)
+		v.checkCategory(path.Join(parentPath, name), subcats[name])
+	}
+}
+
+func (v *configValidator) checkExtensions(categoryPath string, extensions []string) {
+	for _, ext := range extensions {
+		trimmed := strings.TrimSpace(ext)
+		if trimmed == "" || trimmed == "." {
+			v.add(IssueError, categoryPath, "empty extension")
+			continue
+		}
+		if kind, pattern, ok := parsePattern(ext); ok {
+			v.checkPattern(categoryPath, kind, pattern)
+			continue
+		}
+		if mediaType, ok := strings.CutPrefix(ext, mimePrefix); ok {
+			v.checkMIMEType(categoryPath, mediaType)
+			continue
+		}
+		if trimmed != ext || strings.ContainsAny(ext, `/\`) {
+			v.add(IssueError, categoryPath, "invalid extension %q", ext)
+			continue
+		}
+
+		// Ensure extension starts with a dot, as buildExtensionMapping does
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+
+		if ext != strings.ToLower(ext) {
+			v.add(IssueWarning, categoryPath, "extension %s contains uppercase letters and will never match, file extensions are compared in lowercase", ext)
+		}
+
+		if previous, exists := v.extensions[ext]; exists {
+			if previous == categoryPath {
+				v.add(IssueWarning, categoryPath, "extension %s is listed more than once", ext)
+			} else {
+				v.add(IssueWarning, categoryPath, "duplicate extension %s, also mapped to %s, %s takes precedence", ext, previous, categoryPath)
+			}
+		}
+		v.extensions[ext] = categoryPath
+	}
+}
+
+// checkPattern reports invalid name patterns and patterns declared by more than one category
+func (v *configValidator) checkPattern(categoryPath, kind, pattern string) {
+	if _, err := compilePattern(kind, pattern); err != nil {
+		v.add(IssueError, categoryPath, "invalid %s pattern %q: %v", kind, pattern, err)
+		return
+	}
+
+	entry := kind + ":" + pattern
+	if previous, exists := v.extensions[entry]; exists {
+		if previous == categoryPath {
+			v.add(IssueWarning, categoryPath, "pattern %s is listed more than once", entry)
+		} else {
+			v.add(IssueWarning, categoryPath, "duplicate pattern %s, also mapped to %s, %s takes precedence", entry, previous, categoryPath)
+		}
+	}
+	v.extensions[entry] = categoryPath
+}
+
+// checkMIMEType reports malformed content types and content types mapped by more than one category
+func (v *configValidator) checkMIMEType(categoryPath, mediaType string) {
+	mediaType, err := normalizeMIMEType(mediaType)
+	if err != nil {
+		v.add(IssueError, categoryPath, "invalid content type: %v", err)
+		return
+	}
+
+	entry := mimePrefix + mediaType
+	if previous, exists := v.extensions[entry]; exists {
+		if previous == categoryPath {
+			v.add(IssueWarning, categoryPath, "content type %s is listed more than once", mediaType)
+		} else {
+			v.add(IssueWarning, categoryPath, "duplicate content type %s, also mapped to %s, %s takes precedence", mediaType, previous, categoryPath)
+		}
+	}
+	v.extensions[entry] = categoryPath
+}
+
+// checkPathTemplates reports path templates that cannot be parsed or belong to no category
+func (v *configValidator) checkPathTemplates(templates map[string]string) {
+	categories := make([]string, 0, len(templates))
+	for category := range templates {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	for _, category := range categories {
+		if _, err := parsePathTemplate(templates[category]); err != nil {
+			v.add(IssueError, category, "%v", err)
+			continue
+		}
+		if !v.categories[strings.Trim(category, "/")] {
+			v.add(IssueWarning, category, "path template is set for a category that does not exist")
+		}
+	}
+}
+
+// checkFolderName reports category names that cannot be used as a directory name
+func (v *configValidator) checkFolderName(parentPath, name string) {
+	categoryPath := path.Join(parentPath, name)
+
+	switch {
+	case strings.TrimSpace(name) == "":
+		v.add(IssueError, parentPath, "category name is empty")
+	case name == "." || name == "..":
+		// Joined with its parent, the name would hide the category it belongs to
+		v.add(IssueError, parentPath, "invalid folder name %q", name)
+	case strings.ContainsAny(name, `/\<>:"|?*`):
+		v.add(IssueError, categoryPath, "invalid folder name %q, contains a reserved character", name)
+	case strings.IndexFunc(name, func(r rune) bool { return r < 0x20 }) >= 0:
+		v.add(IssueError, categoryPath, "invalid folder name %q, contains a control character", name)
+	case strings.HasSuffix(name, ".") || strings.HasSuffix(name, " "):
+		v.add(IssueError, categoryPath, "invalid folder name %q, cannot end with a dot or space", name)
+	case reservedFolderNames[strings.ToUpper(strings.SplitN(name, ".", 2)[0])]:
+		v.add(IssueError, categoryPath, "invalid folder name %q, reserved on Windows", name)
+	}
+}
+
+// compactJSON returns a single-line representation of a raw JSON value for error messages
+func compactJSON(data json.RawMessage) string {
+	text := strings.Join(strings.Fields(string(data)), " ")
+	if len(text) > 60 {
+		text = text[:57] + "..."
+	}
+	return text
+}
+
+// checkRules reports size and age rules that cannot be parsed, belong to no category or match
+// every file
+func (v *configValidator) checkRules(rules []Rule) {
+	for i, rule := range rules {
+		name := fmt.Sprintf("rules[%d]", i+1)
+		parsed, err := parseRule(rule)
+		if err != nil {
+			v.add(IssueError, name, "%v", err)
+			continue
+		}
+		if parsed.category != "" && !v.categories[parsed.category] {
+			v.add(IssueWarning, name, "rule applies to category %s, which does not exist", rule.Category)
+		}
+		if parsed.minSize == 0 && parsed.maxSize == 0 && parsed.minAge == 0 && parsed.maxAge == 0 {
+			v.add(IssueWarning, name, "rule has no size or age condition, so every file of its category matches it")
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package organizer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		// want lists the expected issues in order, as "severity category: message", where the
+		// message only needs to be contained in the reported one
+		want []string
+	}{
+		{
+			name: "valid config",
+			config: `{
+  "categories": {"images": [".jpg", "glob:IMG_*", "mime:image/*"], "documents": {"office": [".docx", ".tar.gz"]}},
+  "path_template": "{category}/{ext}",
+  "path_templates": {"images": "{category}/{year}", "documents/office/": "office"},
+  "rules": [{"category": "images", "min_size": "1MB", "path": "large"}],
+  "ignore": ["*.tmp", "!keep.tmp"],
+  "rename": {"template": "{date}_{name}{ext}", "sanitize": true}
+}`,
+		},
+		{
+			name:   "no categories",
+			config: `{}`,
+			want:   []string{"error : config does not define any categories"},
+		},
+		{
+			name:   "empty categories",
+			config: `{"categories": {"a": [], "b": {}, "c": [[], {}, [".jpg"]]}}`,
+			want: []string{
+				"warning a: category is empty",
+				"warning b: category is empty",
+				"warning c: entry 1 is an empty extension list",
+				"warning c: entry 2 is an empty subcategory object",
+			},
+		},
+		{
+			name:   "malformed entries",
+			config: `{"categories": {"a": [[".jpg"], 5], "b": 5}}`,
+			want: []string{
+				"error a: entry 2 is ignored, expected an extension list or subcategory object but got 5",
+				"error b: unable to parse category",
+			},
+		},
+		{
+			name:   "invalid extensions",
+			config: `{"categories": {"a": ["", ".", " .jpg", "x/y", "png", ".PNG"]}}`,
+			want: []string{
+				"error a: empty extension",
+				"error a: empty extension",
+				`error a: invalid extension " .jpg"`,
+				`error a: invalid extension "x/y"`,
+				"warning a: extension .PNG contains uppercase letters",
+			},
+		},
+		{
+			name:   "duplicate extensions",
+			config: `{"categories": {"b": {"raw": [".jpg"]}, "a": [".jpg", "jpg"]}}`,
+			want: []string{
+				"warning a: duplicate extension .jpg, also mapped to b/raw, a takes precedence",
+				"warning a: extension .jpg is listed more than once",
+			},
+		},
+		{
+			name:   "own extensions before subcategories",
+			config: `{"categories": {"a": [{"sub": [".jpg"]}, [".jpg"]]}}`,
+			want:   []string{"warning a/sub: duplicate extension .jpg, also mapped to a, a/sub takes precedence"},
+		},
+		{
+			name:   "name patterns",
+			config: `{"categories": {"a": ["glob:[", "regex:(", "glob:", "regex:^x$"], "b": ["regex:^x$", "regex:^x$"]}}`,
+			want: []string{
+				`error a: invalid glob pattern "["`,
+				`error a: invalid regex pattern "("`,
+				`error a: invalid glob pattern ""`,
+				"warning b: duplicate pattern regex:^x$, also mapped to a, b takes precedence",
+				"warning b: pattern regex:^x$ is listed more than once",
+			},
+		},
+		{
+			name:   "content types",
+			config: `{"categories": {"a": ["mime:image", "mime:image/png", "mime:IMAGE/PNG"], "b": ["mime:image/png"]}}`,
+			want: []string{
+				"error a: invalid content type",
+				"warning a: content type image/png is listed more than once",
+				"warning b: duplicate content type image/png, also mapped to a, b takes precedence",
+			},
+		},
+		{
+			name:   "folder names",
+			config: `{"categories": {" ": [".a"], "a:b": [".b"], "tab\t": [".c"], "dot.": [".d"], "CON": [".e"], "nul.txt": [".f"], "x": {"..": [".g"], "com1": [".h"]}, "console": [".i"]}}`,
+			want: []string{
+				"error : category name is empty",
+				`error a:b: invalid folder name "a:b", contains a reserved character`,
+				`error tab` + "\t" + `: invalid folder name "tab\t", contains a control character`,
+				`error dot.: invalid folder name "dot.", cannot end with a dot or space`,
+				`error CON: invalid folder name "CON", reserved on Windows`,
+				`error nul.txt: invalid folder name "nul.txt", reserved on Windows`,
+				`error x: invalid folder name ".."`,
+				`error x/com1: invalid folder name "com1", reserved on Windows`,
+			},
+		},
+		{
+			name:   "path templates",
+			config: `{"categories": {"a": [".jpg"]}, "path_templates": {"a": "{bogus}", "missing": "{category}"}, "path_template": "/abs"}`,
+			want: []string{
+				`error a: uses unknown variable {bogus}`,
+				"warning missing: path template is set for a category that does not exist",
+				`error path_template: path template "/abs" must be relative`,
+			},
+		},
+		{
+			name: "rules",
+			config: `{"categories": {"a": [".jpg"]}, "rules": [
+  {"min_size": "big", "path": "x"},
+  {"min_age": "2m3", "path": "x"},
+  {"min_size": "2GB", "max_size": "1GB", "path": "x"},
+  {"min_size": "1MB"},
+  {"category": "nope", "min_size": "1MB", "path": "x"},
+  {"category": "a", "path": "x"}
+]}`,
+			want: []string{
+				`error rules[1]: invalid size "big"`,
+				`error rules[2]: invalid age "2m3"`,
+				"error rules[3]: min_size 2GB is larger than max_size 1GB",
+				"error rules[4]: empty path template",
+				"warning rules[5]: rule applies to category nope, which does not exist",
+				"warning rules[6]: rule has no size or age condition",
+			},
+		},
+		{
+			name:   "ignore and rename",
+			config: `{"categories": {"a": [".jpg"]}, "ignore": ["*.tmp", "/"], "rename": {"template": "{name}/{ext}"}}`,
+			want: []string{
+				`error ignore: invalid ignore pattern "/"`,
+				"error rename: must not contain path separators",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseConfig([]byte(tt.config), ConfigFormatJSON)
+			if err != nil {
+				t.Fatalf("ParseConfig: %v", err)
+			}
+
+			var got []string
+			for _, issue := range Validate(config) {
+				got = append(got, fmt.Sprintf("%s %s: %s", issue.Severity, issue.Category, issue.Message))
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Validate reported %d issues, want %d\ngot  %q\nwant %q", len(got), len(tt.want), got, tt.want)
+			}
+			for i := range got {
+				prefix, message, _ := strings.Cut(tt.want[i], ": ")
+				if !strings.HasPrefix(got[i], prefix+": ") || !strings.Contains(got[i], message) {
+					t.Errorf("issue %d = %q, want %q", i+1, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package organizer
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"sync"
+)
+
+// dirQueuePerWorker is how many directories may wait to be read per scan worker. Once the queue
+// is full, workers read the subdirectories they find themselves
+const dirQueuePerWorker = 64
+
+// parallelWalker reads the directories of a tree with several goroutines
+type parallelWalker struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	fsys   FileSystem
+	fn     func(path string, d fs.DirEntry) error
+	queue  chan string
+	// pending counts the directories queued or being read
+	pending sync.WaitGroup
+	once    sync.Once
+	err     error
+}
+
+// parallelWalk walks the tree rooted at root, reading up to workers directories at a time. fn is
+// called for every file and directory, concurrently from several goroutines, in no particular
+// order across directories. As with FileSystem.WalkDir, returning fs.SkipDir from fn skips a
+// directory and fs.SkipAll stops the walk. Any other error stops the walk and is returned.
+// Memory stays bounded on trees of any size, as only a fixed number of directories is queued
+func parallelWalk(ctx context.Context, fsys FileSystem, root string, workers int, fn func(path string, d fs.DirEntry) error) error {
+	info, err := fsys.Lstat(root)
+	if err != nil {
+		return err
+	}
+	rootEntry := fs.FileInfoToDirEntry(info)
+	if err := fn(root, rootEntry); err != nil || !rootEntry.IsDir() {
+		if err == fs.SkipDir || err == fs.SkipAll {
+			return nil
+		}
+		return err
+	}
+
+	w := &parallelWalker{
+		fsys:  fsys,
+		fn:    fn,
+		queue: make(chan string, workers*dirQueuePerWorker),
+	}
+	w.ctx, w.cancel = context.WithCancel(ctx)
+	defer w.cancel()
+
+	w.pending.Add(1)
+	w.queue <- root
+
+	// The queue is closed once every directory has been read, which stops the workers
+	go func() {
+		w.pending.Wait()
+		close(w.queue)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for dir := range w.queue {
+				w.readDir(dir)
+				w.pending.Done()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return w.err
+}
+
+// readDir calls fn for the entries of a directory, queueing its subdirectories
+func (w *parallelWalker) readDir(dir string) {
+	if w.ctx.Err() != nil {
+		return
+	}
+
+	entries, err := w.fsys.ReadDir(dir)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+
+	for _, entry := range entries {
+		if w.ctx.Err() != nil {
+			return
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		switch err := w.fn(path, entry); {
+		case err == fs.SkipDir && entry.IsDir():
+			continue
+		case err == fs.SkipDir:
+			// Skipping from a file skips the rest of its directory
+			return
+		case err == fs.SkipAll:
+			w.cancel()
+			return
+		case err != nil:
+			w.fail(err)
+			return
+		}
+		if !entry.IsDir() {
+			continue
+		}
+
+		// Hand the subdirectory to another worker, or read it right away when the queue is full
+		w.pending.Add(1)
+		select {
+		case w.queue <- path:
+		default:
+			w.pending.Done()
+			w.readDir(path)
+		}
+	}
+}
+
+// fail stops the walk, keeping the first error
+func (w *parallelWalker) fail(err error) {
+	w.once.Do(func() {
+		w.err = err
+		w.cancel()
+	})
+}
@@ -0,0 +1,220 @@
+package organizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newWalkerTestFS builds a tree wider than the directory queue of a single scan worker, so
+// parallelWalk both queues directories and reads them inline
+func newWalkerTestFS(t *testing.T) *MemFS {
+	t.Helper()
+	files := map[string]string{
+		"/src/top.jpg":                  "jpg",
+		"/src/top.tmp":                  "tmp",
+		"/src/notes.txt":                "txt",
+		"/src/cache/data.jpg":           "jpg",
+		"/src/deep/a/b/c/d/photo.jpg":   "jpg",
+		"/src/deep/a/cache/photo.jpg":   "jpg",
+		"/src/kept/.organizerignore":    "*.jpg\n!keep*.jpg\n",
+		"/src/kept/skipped.jpg":         "jpg",
+		"/src/kept/keep.jpg":            "jpg",
+		"/src/kept/inner/skipped.jpg":   "jpg",
+		"/src/sorted/images/old.jpg":    "jpg",
+		"/src/sorted/images/jpg/a.jpg":  "jpg",
+		"/src/deep/a/b/c/d/e/notes.txt": "txt",
+	}
+	for i := range dirQueuePerWorker * 2 {
+		dir := fmt.Sprintf("/src/dir%03d", i)
+		files[dir+"/photo.jpg"] = "jpg"
+		files[dir+"/sub/photo.tmp"] = "tmp"
+	}
+	return newTestMemFS(t, files)
+}
+
+// collectWalk walks root with parallelWalk, returning the sorted paths fn was called with
+func collectWalk(t *testing.T, fsys FileSystem, root string, workers int, fn func(path string, d fs.DirEntry) error) []string {
+	t.Helper()
+	var mu sync.Mutex
+	var paths []string
+	err := parallelWalk(context.Background(), fsys, root, workers, func(path string, d fs.DirEntry) error {
+		mu.Lock()
+		paths = append(paths, path)
+		mu.Unlock()
+		return fn(path, d)
+	})
+	if err != nil {
+		t.Fatalf("parallelWalk with %d workers: %v", workers, err)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// collectWalkDir walks root with FileSystem.WalkDir, returning the sorted paths fn was called with
+func collectWalkDir(t *testing.T, fsys FileSystem, root string, fn func(path string, d fs.DirEntry) error) []string {
+	t.Helper()
+	var paths []string
+	err := fsys.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+		return fn(path, d)
+	})
+	if err != nil {
+		t.Fatalf("WalkDir: %v", err)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestParallelWalkMatchesWalkDir(t *testing.T) {
+	memFS := newWalkerTestFS(t)
+
+	skipCache := func(path string, d fs.DirEntry) error {
+		if d.IsDir() && d.Name() == "cache" {
+			return fs.SkipDir
+		}
+		return nil
+	}
+	// Skipping from a file skips the rest of its directory, which is read in name order
+	skipAfterKeep := func(path string, d fs.DirEntry) error {
+		if d.Name() == "keep.jpg" {
+			return fs.SkipDir
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name string
+		fn   func(path string, d fs.DirEntry) error
+	}{
+		{"every entry", func(string, fs.DirEntry) error { return nil }},
+		{"skipped directories", skipCache},
+		{"skipped from a file", skipAfterKeep},
+	}
+
+	for _, tt := range tests {
+		want := collectWalkDir(t, memFS, "/src", tt.fn)
+		for _, workers := range []int{1, 2, 8} {
+			t.Run(fmt.Sprintf("%s/%d workers", tt.name, workers), func(t *testing.T) {
+				if got := collectWalk(t, memFS, "/src", workers, tt.fn); !slices.Equal(got, want) {
+					t.Errorf("parallelWalk found %d entries, WalkDir %d\ngot  %v\nwant %v", len(got), len(want), got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestParallelWalkStops(t *testing.T) {
+	memFS := newWalkerTestFS(t)
+	errStop := errors.New("stop")
+
+	for _, workers := range []int{1, 8} {
+		err := parallelWalk(context.Background(), memFS, "/src", workers, func(path string, d fs.DirEntry) error {
+			if strings.HasSuffix(path, "dir050") {
+				return errStop
+			}
+			return nil
+		})
+		if !errors.Is(err, errStop) {
+			t.Errorf("parallelWalk with %d workers returned %v, want the error of fn", workers, err)
+		}
+
+		if err := parallelWalk(context.Background(), memFS, "/src", workers, func(path string, d fs.DirEntry) error {
+			return fs.SkipAll
+		}); err != nil {
+			t.Errorf("parallelWalk with %d workers stopped by SkipAll returned %v", workers, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var calls int
+	parallelWalk(ctx, memFS, "/src", 4, func(path string, d fs.DirEntry) error {
+		calls++
+		return nil
+	})
+	if calls > 1 {
+		t.Errorf("a cancelled walk visited %d entries, want only the root", calls)
+	}
+
+	if err := parallelWalk(context.Background(), memFS, "/missing", 4, func(string, fs.DirEntry) error { return nil }); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("parallelWalk of a missing root returned %v, want ErrNotExist", err)
+	}
+}
+
+func TestRunWalkScanWorkers(t *testing.T) {
+	// The destination inside the source and the ignore rules leave parts of the tree unscanned
+	org := newTestOrganizer(t, `{"categories": {"images": [".jpg"]}, "ignore": ["*.tmp", "cache/"]}`)
+	memFS := newWalkerTestFS(t)
+
+	walkFiles := func(scanWorkers int) []string {
+		run, err := org.newRun(Options{SourcePath: "/src", DestPath: "/src/sorted", Recursive: true, ScanWorkers: scanWorkers, FS: memFS, Exclude: []string{"notes.txt"}})
+		if err != nil {
+			t.Fatalf("newRun: %v", err)
+		}
+		var mu sync.Mutex
+		var files []string
+		err = run.walk(context.Background(), func(path string, d fs.DirEntry) error {
+			mu.Lock()
+			files = append(files, path)
+			mu.Unlock()
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("walk with %d scan workers: %v", scanWorkers, err)
+		}
+		sort.Strings(files)
+		return files
+	}
+
+	want := walkFiles(1)
+	if len(want) != dirQueuePerWorker*2+3 {
+		t.Fatalf("sequential walk found %d files, want %d: %v", len(want), dirQueuePerWorker*2+3, want)
+	}
+	for _, path := range want {
+		name := filepath.Base(path)
+		if strings.HasSuffix(name, ".tmp") || name == "notes.txt" || name == "skipped.jpg" || strings.Contains(path, "/cache/") || strings.HasPrefix(path, "/src/sorted/") {
+			t.Errorf("sequential walk found ignored file %s", path)
+		}
+	}
+
+	for _, scanWorkers := range []int{2, 8} {
+		if got := walkFiles(scanWorkers); !slices.Equal(got, want) {
+			t.Errorf("walk with %d scan workers found %v, want %v", scanWorkers, got, want)
+		}
+	}
+
+	// The total counted by the run agrees with the walk, and so do the planned moves
+	var wantTargets []string
+	for _, scanWorkers := range []int{1, 8} {
+		stats := &Stats{}
+		if _, err := org.Plan(context.Background(), Options{SourcePath: "/src", DestPath: "/src/sorted", NumWorkers: 4, Recursive: true, ScanWorkers: scanWorkers, FS: memFS, Exclude: []string{"notes.txt"}, Stats: stats}); err != nil {
+			t.Fatalf("Plan with %d scan workers: %v", scanWorkers, err)
+		}
+		if stats.TotalFiles != len(want) || stats.ProcessedFiles != len(want) {
+			t.Errorf("Plan with %d scan workers counted %d files and processed %d, want %d", scanWorkers, stats.TotalFiles, stats.ProcessedFiles, len(want))
+		}
+
+		// Which file gets a collision suffix depends on the order the workers get to it
+		var targets []string
+		for _, move := range stats.Moves {
+			targets = append(targets, move.Source+" -> "+filepath.Dir(move.Target))
+		}
+		sort.Strings(targets)
+		if wantTargets == nil {
+			wantTargets = targets
+		} else if !slices.Equal(targets, wantTargets) {
+			t.Errorf("Plan with %d scan workers planned %v, want %v", scanWorkers, targets, wantTargets)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package organizer
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watch watches the source directory and organizes files as they arrive. A file is only handed
+// to the workers once it has not been created or written to for the settle delay, so
+// half-written files are left alone. It runs until ctx is cancelled, then waits for the workers
+// to finish (or roll back) the files they are working on. Files that could not be organized do
+// not stop the watch, the stats are returned with Stats.Err, which joins them. Watching needs
+// change notifications from the operating system, so it only works on OSFS
+func (o *Organizer) Watch(ctx context.Context, opts Options, watchOpts WatchOptions) (*Stats, error) {
+	run, err := o.newRun(opts)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := run.opts.FS.(OSFS); !ok {
+		return nil, fmt.Errorf("watching is only supported on the OS filesystem")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("error starting watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	// pending maps each file waiting to settle to the time of its last event
+	pending := make(map[string]time.Time)
+
+	// Watch the source (and its subdirectories when recursive), queueing the files found in it
+	// as if their last event happened at queuedAt
+	watchTree := func(root string, queueFiles bool, queuedAt time.Time) {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if run.ignored(path, d.IsDir()) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if !d.IsDir() {
+				if queueFiles {
+					pending[path] = queuedAt
+				}
+				return nil
+			}
+			if path != run.opts.SourcePath && (!run.opts.Recursive || run.isOrganizedDir(path)) {
+				return fs.SkipDir
+			}
+			if err := watcher.Add(path); err != nil {
+				run.watchError(fmt.Errorf("watch %s: %w", path, err))
+			}
+			return nil
+		})
+	}
+	watchTree(run.opts.SourcePath, watchOpts.ScanExisting, time.Time{})
+
+	// Create a channel for jobs and start the workers
+	jobs := make(chan fileJob, 100)
+	wg := run.startWorkers(ctx, jobs)
+
+	settle := watchOpts.Settle
+	tick := settle / 4
+	if tick < 100*time.Millisecond {
+		tick = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+watch:
+	for {
+		select {
+		case <-ctx.Done():
+			break watch
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				break watch
+			}
+
+			switch {
+			case event.Has(fsnotify.Create):
+				info, err := os.Lstat(event.Name)
+				if err != nil {
+					continue
+				}
+				if info.IsDir() {
+					// Files can land in a new directory before it is watched, queue what is already there
+					if run.opts.Recursive && !run.isOrganizedDir(event.Name) && !run.ignores.excluded(event.Name, true) {
+						watchTree(event.Name, true, time.Now())
+					}
+					continue
+				}
+				pending[event.Name] = time.Now()
+			case event.Has(fsnotify.Write):
+				pending[event.Name] = time.Now()
+			case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+				delete(pending, event.Name)
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				break watch
+			}
+			run.watchError(fmt.Errorf("watch: %w", err))
+
+		case now := <-ticker.C:
+			for path, lastEvent := range pending {
+				if now.Sub(lastEvent) < settle {
+					continue
+				}
+				delete(pending, path)
+
+				// Only regular files that still exist are organized
+				info, err := os.Lstat(path)
+				if err != nil || !info.Mode().IsRegular() {
+					continue
+				}
+				if info.Name() == ignoreFileName || run.ignores.excluded(path, false) {
+					continue
+				}
+				// Wait for files that are still written to, or too recent, to settle again
+				if _, busy := run.inUse(path); busy {
+					pending[path] = now
+					continue
+				}
+
+				run.stats.IncrementTotal()
+				job, ok := run.newJob(path, info.Name())
+				if !ok {
+					continue
+				}
+
+				select {
+				case jobs <- job:
+				case <-ctx.Done():
+					break watch
+				}
+			}
+		}
+	}
+
+	// Close the jobs channel and wait for the workers to finish their current file
+	close(jobs)
+	wg.Wait()
+
+	return run.stats, run.stats.Err()
+}
+
+// watchError reports a problem that does not stop the watch to Options.OnError, or records it as
+// a warning
+func (r *organizeRun) watchError(err error) {
+	if r.opts.OnError != nil {
+		r.opts.OnError(err)
+		return
+	}
+	r.stats.AddWarning(err.Error())
+}
+
+// isOrganizedDir reports whether dir holds organized files: a destination root inside the
+// source, or a category folder directly under the destination root
+func (r *organizeRun) isOrganizedDir(dir string) bool {
+	if r.isNestedDest(dir) {
+		return true
+	}
+	return filepath.Dir(dir) == r.destRoot && r.targetFolders[filepath.Base(dir)]
+}